@@ -15,6 +15,9 @@ import (
 
 var DB *gorm.DB
 
+// defaultPassword was given to users created before accounts had passwords.
+var defaultPassword = []byte("changeme123")
+
 func Connect() {
 	err := godotenv.Load()
 	if err != nil {
@@ -31,7 +34,8 @@ func Connect() {
 		}
 	}
 
-	db, err := gorm.Open(postgres.Open(dbURL), &gorm.Config{})
+	// TranslateError turns unique violations into gorm.ErrDuplicatedKey
+	db, err := gorm.Open(postgres.Open(dbURL), &gorm.Config{TranslateError: true})
 	if err != nil {
 		log.Fatal("Failed to connect to database")
 	}
@@ -75,7 +79,6 @@ func Connect() {
 			}
 
 			// Generate a default hashed password for existing users
-			defaultPassword := []byte("changeme123")
 			hashedPassword, err := bcrypt.GenerateFromPassword(defaultPassword, bcrypt.DefaultCost)
 			if err != nil {
 				log.Fatal("Failed to generate default password:", err)
@@ -91,10 +94,137 @@ func Connect() {
 				log.Fatal("Failed to make password non-nullable:", err)
 			}
 		}
+
+		// Flag accounts still using the seeded default password so they have to
+		// pick their own before using the API
+		if !db.Migrator().HasColumn(&models.User{}, "must_change_password") {
+			if err := db.Exec("ALTER TABLE users ADD COLUMN must_change_password boolean NOT NULL DEFAULT false").Error; err != nil {
+				log.Fatal("Failed to add must_change_password column:", err)
+			}
+
+			var users []models.User
+			if err := db.Select("id", "password").Find(&users).Error; err != nil {
+				log.Fatal("Failed to load users:", err)
+			}
+			for _, user := range users {
+				if bcrypt.CompareHashAndPassword([]byte(user.Password), defaultPassword) != nil {
+					continue
+				}
+				if err := db.Exec("UPDATE users SET must_change_password = true WHERE id = ?", user.ID).Error; err != nil {
+					log.Fatal("Failed to flag user with default password:", err)
+				}
+			}
+		}
+	}
+
+	// Handle report day settings for existing babies
+	if db.Migrator().HasTable(&models.Baby{}) {
+		if !db.Migrator().HasColumn(&models.Baby{}, "timezone") {
+			if err := db.Exec("ALTER TABLE babies ADD COLUMN timezone text").Error; err != nil {
+				log.Fatal("Failed to add timezone column:", err)
+			}
+
+			// Existing reports were computed in Europe/Paris
+			if err := db.Exec("UPDATE babies SET timezone = ? WHERE timezone IS NULL", models.DefaultTimezone).Error; err != nil {
+				log.Fatal("Failed to update existing babies with default timezone:", err)
+			}
+
+			if err := db.Exec("ALTER TABLE babies ALTER COLUMN timezone SET NOT NULL").Error; err != nil {
+				log.Fatal("Failed to make timezone non-nullable:", err)
+			}
+		}
+
+		if !db.Migrator().HasColumn(&models.Baby{}, "day_start_hour") {
+			if err := db.Exec("ALTER TABLE babies ADD COLUMN day_start_hour bigint").Error; err != nil {
+				log.Fatal("Failed to add day_start_hour column:", err)
+			}
+
+			if err := db.Exec("UPDATE babies SET day_start_hour = ? WHERE day_start_hour IS NULL", models.DefaultDayStartHour).Error; err != nil {
+				log.Fatal("Failed to update existing babies with default day start hour:", err)
+			}
+
+			if err := db.Exec("ALTER TABLE babies ALTER COLUMN day_start_hour SET NOT NULL").Error; err != nil {
+				log.Fatal("Failed to make day_start_hour non-nullable:", err)
+			}
+		}
+
+		for column, hour := range map[string]int{"night_start_hour": models.DefaultNightStartHour, "night_end_hour": models.DefaultNightEndHour} {
+			if db.Migrator().HasColumn(&models.Baby{}, column) {
+				continue
+			}
+
+			if err := db.Exec("ALTER TABLE babies ADD COLUMN " + column + " bigint").Error; err != nil {
+				log.Fatal("Failed to add "+column+" column:", err)
+			}
+
+			if err := db.Exec("UPDATE babies SET "+column+" = ? WHERE "+column+" IS NULL", hour).Error; err != nil {
+				log.Fatal("Failed to update existing babies with default "+column+":", err)
+			}
+
+			if err := db.Exec("ALTER TABLE babies ALTER COLUMN " + column + " SET NOT NULL").Error; err != nil {
+				log.Fatal("Failed to make "+column+" non-nullable:", err)
+			}
+		}
+	}
+
+	// Event tables gained timestamps for delta sync, and so did the records
+	// they refer to later. Existing rows are stamped with the migration time
+	// so that no NULL reaches the models.
+	for _, model := range []interface{}{&models.Sleep{}, &models.Diaper{}, &models.Nursing{}, &models.Measurement{}, &models.MedicationDose{}, &models.Temperature{}, &models.Symptom{}, &models.Vaccination{}, &models.FoodIntroduction{}, &models.Milestone{}, &models.CustomEvent{}, &models.IllnessEpisode{}, &models.Medication{}, &models.EventType{}, &models.MilkStash{}} {
+		if !db.Migrator().HasTable(model) || db.Migrator().HasColumn(model, "updated_at") {
+			continue
+		}
+
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			log.Fatal("Failed to parse model:", err)
+		}
+		table := stmt.Schema.Table
+
+		if err := db.Exec("ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS created_at timestamptz, ADD COLUMN updated_at timestamptz").Error; err != nil {
+			log.Fatal("Failed to add timestamp columns to "+table+":", err)
+		}
+		if err := db.Exec("UPDATE " + table + " SET created_at = COALESCE(created_at, now()), updated_at = COALESCE(created_at, now())").Error; err != nil {
+			log.Fatal("Failed to stamp existing "+table+":", err)
+		}
+	}
+
+	// Memberships carry a role; rows created before roles existed become owners
+	if err := db.SetupJoinTable(&models.User{}, "Babies", &models.UserBaby{}); err != nil {
+		log.Fatal("Failed to set up user_babies join table:", err)
+	}
+	if err := db.SetupJoinTable(&models.Baby{}, "Parents", &models.UserBaby{}); err != nil {
+		log.Fatal("Failed to set up user_babies join table:", err)
+	}
+
+	// Only one timer can run per baby. Timers started concurrently before
+	// that was enforced are stopped when the next one started, so that the
+	// unique indexes can be built.
+	for _, timer := range []struct {
+		model        interface{}
+		table, start string
+		index        string
+	}{
+		{&models.Sleep{}, "sleeps", "start", "idx_sleep_running"},
+		{&models.Nursing{}, "nursings", "time", "idx_nursing_running"},
+	} {
+		if !db.Migrator().HasTable(timer.model) || db.Migrator().HasIndex(timer.model, timer.index) {
+			continue
+		}
+		// Tables from before the trash have no deleted_at yet
+		liveN, liveT := "", ""
+		if db.Migrator().HasColumn(timer.model, "deleted_at") {
+			liveN, liveT = " AND n.deleted_at IS NULL", " AND t.deleted_at IS NULL"
+		}
+		later := "FROM " + timer.table + " n WHERE n.baby_id = t.baby_id AND n.in_progress" + liveN + " AND (n." + timer.start + ", n.id) > (t." + timer.start + ", t.id)"
+		if err := db.Exec("UPDATE " + timer.table + " t SET in_progress = false, \"end\" = (SELECT min(n." + timer.start + ") " + later + ") " +
+			"WHERE t.in_progress" + liveT + " AND EXISTS (SELECT 1 " + later + ")").Error; err != nil {
+			log.Fatal("Failed to stop concurrent "+timer.table+" timers:", err)
+		}
 	}
 
 	// Run normal migrations
-	err = db.AutoMigrate(&models.User{}, &models.Baby{}, &models.Sleep{}, &models.Diaper{}, &models.Nursing{})
+	err = db.AutoMigrate(&models.User{}, &models.Baby{}, &models.UserBaby{}, &models.Sleep{}, &models.Diaper{}, &models.Nursing{}, &models.Measurement{}, &models.Invitation{}, &models.Session{}, &models.PasswordResetToken{}, &models.MilkStash{}, &models.MilkConsumption{}, &models.Medication{}, &models.MedicationDose{}, &models.Temperature{}, &models.IllnessEpisode{}, &models.Symptom{}, &models.Vaccination{}, &models.FoodIntroduction{}, &models.Milestone{}, &models.Attachment{}, &models.EventType{}, &models.CustomEvent{}, &models.Tombstone{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
@@ -0,0 +1,166 @@
+// Package growth computes WHO growth standard z-scores and percentiles from the
+// LMS reference tables bundled in the data directory.
+package growth
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+type Indicator string
+
+const (
+	Weight            Indicator = "weight"
+	Length            Indicator = "length"
+	HeadCircumference Indicator = "headCircumference"
+)
+
+// Indicators lists every indicator with a bundled reference table.
+var Indicators = []Indicator{Weight, Length, HeadCircumference}
+
+// daysPerMonth is the average month length the WHO standards use to convert
+// an age in days to months.
+const daysPerMonth = 30.4375
+
+var ErrOutOfRange = errors.New("age outside of reference table range")
+
+//go:embed data/*.json
+var dataFS embed.FS
+
+var tableFiles = map[Indicator]string{
+	Weight:            "data/weight_for_age.json",
+	Length:            "data/length_for_age.json",
+	HeadCircumference: "data/head_circumference_for_age.json",
+}
+
+type lms struct {
+	L, M, S float64
+}
+
+type table struct {
+	Unit   string
+	Male   []lms
+	Female []lms
+}
+
+var tables = map[Indicator]table{}
+
+func init() {
+	for indicator, file := range tableFiles {
+		raw, err := dataFS.ReadFile(file)
+		if err != nil {
+			panic(fmt.Sprintf("growth: reading %s: %v", file, err))
+		}
+
+		var parsed struct {
+			Unit   string       `json:"unit"`
+			Male   [][4]float64 `json:"male"`
+			Female [][4]float64 `json:"female"`
+		}
+		if err := json.Unmarshal(raw, &parsed); err != nil {
+			panic(fmt.Sprintf("growth: parsing %s: %v", file, err))
+		}
+
+		tables[indicator] = table{
+			Unit:   parsed.Unit,
+			Male:   toLMS(parsed.Male),
+			Female: toLMS(parsed.Female),
+		}
+	}
+}
+
+// toLMS converts [month, L, M, S] rows to a slice indexed by month.
+func toLMS(rows [][4]float64) []lms {
+	out := make([]lms, len(rows))
+	for _, row := range rows {
+		out[int(row[0])] = lms{L: row[1], M: row[2], S: row[3]}
+	}
+	return out
+}
+
+// Unit returns the unit values of the indicator are expressed in.
+func Unit(indicator Indicator) string {
+	return tables[indicator].Unit
+}
+
+// MaxAgeDays returns the oldest age covered by the reference tables.
+func MaxAgeDays(indicator Indicator) float64 {
+	return float64(len(tables[indicator].Male)-1) * daysPerMonth
+}
+
+// lookup interpolates the LMS parameters linearly between monthly rows.
+func lookup(indicator Indicator, sex string, ageDays float64) (lms, error) {
+	t, ok := tables[indicator]
+	if !ok {
+		return lms{}, fmt.Errorf("unknown indicator %q", indicator)
+	}
+
+	var rows []lms
+	switch sex {
+	case "male":
+		rows = t.Male
+	case "female":
+		rows = t.Female
+	default:
+		return lms{}, fmt.Errorf("unknown sex %q", sex)
+	}
+
+	months := ageDays / daysPerMonth
+	if months < 0 || months > float64(len(rows)-1) {
+		return lms{}, ErrOutOfRange
+	}
+
+	lower := int(math.Floor(months))
+	if lower == len(rows)-1 {
+		return rows[lower], nil
+	}
+	frac := months - float64(lower)
+	a, b := rows[lower], rows[lower+1]
+	return lms{
+		L: a.L + (b.L-a.L)*frac,
+		M: a.M + (b.M-a.M)*frac,
+		S: a.S + (b.S-a.S)*frac,
+	}, nil
+}
+
+// ZScore returns the z-score of a measurement for a child of the given sex
+// ("male" or "female") and age in days.
+func ZScore(indicator Indicator, sex string, ageDays, value float64) (float64, error) {
+	p, err := lookup(indicator, sex, ageDays)
+	if err != nil {
+		return 0, err
+	}
+	if value <= 0 {
+		return 0, errors.New("measurement must be positive")
+	}
+	if p.L == 0 {
+		return math.Log(value/p.M) / p.S, nil
+	}
+	return (math.Pow(value/p.M, p.L) - 1) / (p.L * p.S), nil
+}
+
+// ValueAt returns the measurement that corresponds to z-score z, which is used
+// to draw reference percentile curves.
+func ValueAt(indicator Indicator, sex string, ageDays, z float64) (float64, error) {
+	p, err := lookup(indicator, sex, ageDays)
+	if err != nil {
+		return 0, err
+	}
+	if p.L == 0 {
+		return p.M * math.Exp(p.S*z), nil
+	}
+	return p.M * math.Pow(1+p.L*p.S*z, 1/p.L), nil
+}
+
+// Percentile converts a z-score to a percentile between 0 and 100.
+func Percentile(z float64) float64 {
+	return 50 * (1 + math.Erf(z/math.Sqrt2))
+}
+
+// ZForPercentile converts a percentile between 0 and 100 to a z-score.
+func ZForPercentile(percentile float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*percentile/100-1)
+}
@@ -0,0 +1,132 @@
+package growth
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// The expected values are the -2 SD, median and +2 SD columns of the WHO
+// Child Growth Standards tables, which are rounded to 0.1.
+func TestValueAtMatchesWHOTables(t *testing.T) {
+	tests := []struct {
+		indicator Indicator
+		sex       string
+		months    float64
+		z         float64
+		want      float64
+	}{
+		{Weight, "male", 0, -2, 2.5},
+		{Weight, "male", 0, 0, 3.3},
+		{Weight, "male", 0, 2, 4.4},
+		{Weight, "female", 0, -2, 2.4},
+		{Weight, "female", 0, 0, 3.2},
+		{Weight, "female", 0, 2, 4.2},
+		{Weight, "male", 6, -2, 6.4},
+		{Weight, "male", 6, 0, 7.9},
+		{Weight, "male", 6, 2, 9.8},
+		{Weight, "male", 12, -2, 7.7},
+		{Weight, "male", 12, 0, 9.6},
+		{Weight, "male", 12, 2, 12.0},
+		{Length, "male", 12, -2, 71.0},
+		{Length, "male", 12, 0, 75.7},
+		{Length, "male", 12, 2, 80.5},
+		{HeadCircumference, "female", 6, -2, 39.6},
+		{HeadCircumference, "female", 6, 0, 42.2},
+		{HeadCircumference, "female", 6, 2, 44.8},
+	}
+	for _, tt := range tests {
+		got, err := ValueAt(tt.indicator, tt.sex, tt.months*daysPerMonth, tt.z)
+		if err != nil {
+			t.Errorf("ValueAt(%s, %s, %g months, %g): %v", tt.indicator, tt.sex, tt.months, tt.z, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 0.05 {
+			t.Errorf("ValueAt(%s, %s, %g months, %g) = %.3f, want %.1f", tt.indicator, tt.sex, tt.months, tt.z, got, tt.want)
+		}
+	}
+}
+
+func TestZScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		indicator Indicator
+		sex       string
+		ageDays   float64
+		value     float64
+		want      float64
+		wantErr   error
+	}{
+		{"median weight at birth", Weight, "male", 0, 3.3464, 0, nil},
+		{"median length at a year", Length, "female", 12 * daysPerMonth, 74.0150, 0, nil},
+		// Length has L = 1, so the z-score is (value / M - 1) / S
+		{"length one SD up", Length, "male", 0, 49.8842 * 1.03795, 1, nil},
+		{"interpolated median", Weight, "male", 0.5 * daysPerMonth, (3.3464 + 4.4709) / 2, 0, nil},
+		{"too old", Weight, "male", 25 * daysPerMonth, 12, 0, ErrOutOfRange},
+		{"before birth", Weight, "male", -1, 3, 0, ErrOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ZScore(tt.indicator, tt.sex, tt.ageDays, tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if math.Abs(got-tt.want) > 0.01 {
+				t.Errorf("ZScore = %.4f, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZScoreRejectsInvalidInput(t *testing.T) {
+	if _, err := ZScore(Weight, "unknown", 0, 3); err == nil {
+		t.Error("unknown sex accepted")
+	}
+	if _, err := ZScore("bmi", "male", 0, 3); err == nil {
+		t.Error("unknown indicator accepted")
+	}
+	if _, err := ZScore(Weight, "male", 0, 0); err == nil {
+		t.Error("zero measurement accepted")
+	}
+}
+
+func TestZScoreInvertsValueAt(t *testing.T) {
+	for _, indicator := range Indicators {
+		for _, z := range []float64{-3, -1.5, 0, 1, 2.5} {
+			ageDays := 100.0
+			value, err := ValueAt(indicator, "female", ageDays, z)
+			if err != nil {
+				t.Fatalf("ValueAt(%s, %g): %v", indicator, z, err)
+			}
+			got, err := ZScore(indicator, "female", ageDays, value)
+			if err != nil {
+				t.Fatalf("ZScore(%s, %g): %v", indicator, value, err)
+			}
+			if math.Abs(got-z) > 1e-9 {
+				t.Errorf("%s: ZScore(ValueAt(%g)) = %g", indicator, z, got)
+			}
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		z    float64
+		want float64
+	}{
+		{0, 50},
+		{1, 84.13},
+		{-1, 15.87},
+		{1.881, 97},
+		{-1.881, 3},
+		{2, 97.72},
+	}
+	for _, tt := range tests {
+		if got := Percentile(tt.z); math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("Percentile(%g) = %.3f, want %g", tt.z, got, tt.want)
+		}
+		if got := ZForPercentile(tt.want); math.Abs(got-tt.z) > 0.001 {
+			t.Errorf("ZForPercentile(%g) = %.4f, want %g", tt.want, got, tt.z)
+		}
+	}
+}
@@ -3,13 +3,17 @@ package main
 import (
 	"baby-tracker/database"
 	"baby-tracker/routers/api"
+	"baby-tracker/storage"
 	"net/http"
+	_ "time/tzdata" // babies can use any IANA timezone, even without system zoneinfo
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
 	database.Connect()
+	storage.Connect()
+	api.StartTrashPurge()
 
 	r := gin.Default()
 
@@ -25,7 +29,8 @@ func main() {
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Parrent-User-ID")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Parrent-User-ID, If-Match, Idempotency-Key")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag, Idempotent-Replayed")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -50,6 +55,10 @@ func main() {
 	{
 		auth.POST("/register", api.Register)
 		auth.POST("/login", api.Login)
+		auth.POST("/refresh", api.Refresh)
+		auth.POST("/logout", api.AuthMiddleware(), api.Logout)
+		auth.POST("/password", api.AuthMiddleware(), api.ChangePassword)
+		auth.POST("/reset-password", api.ResetPassword)
 	}
 
 	// Frontend pages (protected by client-side auth)
@@ -76,7 +85,22 @@ func main() {
 			api.SetupDiaperRoutes(protected)
 			api.SetupBabyRoutes(protected)
 			api.SetupReportRoutes(protected)
+			api.SetupAnalyticsRoutes(protected)
 			api.SetupNursingRoutes(protected)
+			api.SetupMeasurementRoutes(protected)
+			api.SetupMilkRoutes(protected)
+			api.SetupMedicationRoutes(protected)
+			api.SetupTemperatureRoutes(protected)
+			api.SetupIllnessRoutes(protected)
+			api.SetupVaccinationRoutes(protected)
+			api.SetupFoodRoutes(protected)
+			api.SetupMilestoneRoutes(protected)
+			api.SetupAttachmentRoutes(protected)
+			api.SetupEventRoutes(protected)
+			api.SetupSyncRoutes(protected)
+			api.SetupTrashRoutes(protected)
+			api.SetupInvitationRoutes(protected)
+			api.SetupAdminRoutes(protected)
 		}
 
 		// Public routes (no auth required)
@@ -1,45 +1,464 @@
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Sleep struct {
-	ID     string    `json:"id" gorm:"primaryKey"`
-	Start  time.Time `json:"start" gorm:"type:timestamptz"`
-	End    time.Time `json:"end" gorm:"type:timestamptz"`
-	BabyID string    `json:"babyId"`
-	Note   string    `json:"note"`
+	ID         string         `json:"id" gorm:"primaryKey"`
+	Start      time.Time      `json:"start" gorm:"type:timestamptz;index:idx_sleep_baby_start,priority:2"`
+	End        *time.Time     `json:"end" gorm:"type:timestamptz"` // nil while the sleep is still running
+	InProgress bool           `json:"inProgress" gorm:"default:false;index"`
+	BabyID     string         `json:"babyId" gorm:"index:idx_sleep_baby_start,priority:1;uniqueIndex:idx_sleep_running,where:in_progress AND deleted_at IS NULL"` // one running sleep per baby
+	Note       string         `json:"note"`
+	Version    int            `json:"version" gorm:"not null;default:1"` // bumped by every update
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt" gorm:"index"` // sync cursor
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`         // in the trash when set
 }
 
 type Diaper struct {
-	ID     string    `json:"id" gorm:"primaryKey"`
-	Type   string    `json:"type"`
-	Time   time.Time `json:"time"`
-	BabyID string    `json:"babyId"`
-	Note   string    `json:"note"`
+	ID        string         `json:"id" gorm:"primaryKey"`
+	Type      string         `json:"type"`
+	Time      time.Time      `json:"time" gorm:"index:idx_diaper_baby_time,priority:2"`
+	BabyID    string         `json:"babyId" gorm:"index:idx_diaper_baby_time,priority:1"`
+	Note      string         `json:"note"`
+	Version   int            `json:"version" gorm:"not null;default:1"` // bumped by every update
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt" gorm:"index"` // sync cursor
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`         // in the trash when set
 }
 
+// Feeding kinds recorded on Nursing.Kind.
+const (
+	FeedingBreast           = "breast"
+	FeedingBottleBreastMilk = "bottle_breast_milk"
+	FeedingBottleFormula    = "bottle_formula"
+	FeedingPumping          = "pumping" // milk expressed, not fed to the baby
+)
+
+// Breast sides recorded on Nursing.Type.
+const (
+	SideLeft  = "left"
+	SideRight = "right"
+	SideBoth  = "both"
+)
+
 type Nursing struct {
-	ID     string    `json:"id" gorm:"primaryKey"`
-	Type   string    `json:"type"`
-	Amount string    `json:"amount"`
-	Time   time.Time `json:"time"`
-	BabyID string    `json:"babyId"`
-	Note   string    `json:"note"`
+	ID         string         `json:"id" gorm:"primaryKey"`
+	Kind       string         `json:"kind" gorm:"not null;default:breast"`
+	Type       string         `json:"type"`   // breast side: left, right or both
+	Amount     string         `json:"amount"` // legacy free-text amount
+	VolumeML   *float64       `json:"volumeMl"`
+	Unit       string         `json:"unit"` // unit the volume was entered in, "ml" or "oz"
+	Time       time.Time      `json:"time" gorm:"index:idx_nursing_baby_time,priority:2"`
+	End        *time.Time     `json:"end" gorm:"type:timestamptz"` // only set for sessions recorded with the timer
+	InProgress bool           `json:"inProgress" gorm:"default:false;index"`
+	BabyID     string         `json:"babyId" gorm:"index:idx_nursing_baby_time,priority:1;uniqueIndex:idx_nursing_running,where:in_progress AND deleted_at IS NULL"` // one running session per baby
+	Note       string         `json:"note"`
+	Version    int            `json:"version" gorm:"not null;default:1"` // bumped by every update
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt" gorm:"index"` // sync cursor
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`         // in the trash when set
+}
+
+// Storage locations for expressed milk.
+const (
+	StorageRoom    = "room"
+	StorageFridge  = "fridge"
+	StorageFreezer = "freezer"
+)
+
+// MilkStash is a container of expressed breast milk. Pumping sessions add to
+// the stash and bottle feeds of breast milk draw from it.
+type MilkStash struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	BabyID      string     `json:"babyId" gorm:"index"`
+	NursingID   *string    `json:"nursingId"` // pumping session the milk came from
+	VolumeML    float64    `json:"volumeMl"`
+	RemainingML float64    `json:"remainingMl"`
+	Location    string     `json:"location"`
+	Thawed      bool       `json:"thawed"` // thawed milk can't be frozen again
+	ExpressedAt time.Time  `json:"expressedAt"`
+	StoredAt    time.Time  `json:"storedAt"` // when the milk was put in its current location
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	DiscardedAt *time.Time `json:"discardedAt,omitempty"`
+	Note        string     `json:"note"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"index"` // sync cursor
+}
+
+// MilkConsumption records how much of a stash entry a bottle feed used, so the
+// stash can be restored if the feed is deleted.
+type MilkConsumption struct {
+	ID        string  `json:"id" gorm:"primaryKey"`
+	BabyID    string  `json:"babyId" gorm:"index"`
+	NursingID string  `json:"nursingId" gorm:"index"`
+	StashID   string  `json:"stashId" gorm:"index"`
+	VolumeML  float64 `json:"volumeMl"`
+}
+
+// Medication is a medicine or supplement given to a baby, with the limits
+// used to catch double doses.
+type Medication struct {
+	ID               string    `json:"id" gorm:"primaryKey"`
+	BabyID           string    `json:"babyId" gorm:"index"`
+	Name             string    `json:"name" gorm:"not null"`
+	Dose             float64   `json:"dose"`             // usual dose, in Unit
+	Unit             string    `json:"unit"`             // e.g. ml, mg, drops
+	MinIntervalHours float64   `json:"minIntervalHours"` // 0 means no minimum interval
+	MaxPerDay        int       `json:"maxPerDay"`        // doses per rolling 24 hours, 0 means no limit
+	Active           bool      `json:"active" gorm:"not null;default:true"`
+	Note             string    `json:"note"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt" gorm:"index"` // sync cursor
+}
+
+// MedicationDose is a dose of a medication given to a baby.
+type MedicationDose struct {
+	ID           string    `json:"id" gorm:"primaryKey"`
+	MedicationID string    `json:"medicationId" gorm:"index"`
+	BabyID       string    `json:"babyId" gorm:"index"`
+	Time         time.Time `json:"time"`
+	Dose         float64   `json:"dose"`
+	Unit         string    `json:"unit"`
+	GivenByID    string    `json:"givenById"`
+	Overridden   bool      `json:"overridden"` // given despite a dosing warning
+	Note         string    `json:"note"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt" gorm:"index"` // sync cursor
+}
+
+// Temperature measurement methods
+const (
+	TemperatureRectal   = "rectal"
+	TemperatureAxillary = "axillary"
+	TemperatureEar      = "ear"
+)
+
+// Temperature is a body temperature reading.
+type Temperature struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	Time      time.Time `json:"time"`
+	Value     float64   `json:"value"` // as read, in Unit
+	Unit      string    `json:"unit"`  // C or F
+	Celsius   float64   `json:"celsius"`
+	Method    string    `json:"method"`
+	BabyID    string    `json:"babyId" gorm:"index"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"index"` // sync cursor
+}
+
+// IllnessEpisode groups what happened while a baby was ill. Temperatures and
+// medication doses belong to an episode by falling within its dates.
+type IllnessEpisode struct {
+	ID        string     `json:"id" gorm:"primaryKey"`
+	BabyID    string     `json:"babyId" gorm:"index"`
+	Name      string     `json:"name"`
+	Start     time.Time  `json:"start"`
+	End       *time.Time `json:"end"` // nil while the baby is still ill
+	Note      string     `json:"note"`
+	Symptoms  []Symptom  `json:"symptoms" gorm:"foreignKey:EpisodeID"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt" gorm:"index"` // sync cursor
+}
+
+// Symptom is a symptom observed during an illness episode.
+type Symptom struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	EpisodeID string    `json:"episodeId" gorm:"index"`
+	BabyID    string    `json:"babyId" gorm:"index"`
+	Time      time.Time `json:"time"`
+	Name      string    `json:"name"`     // e.g. cough, vomiting, rash
+	Severity  string    `json:"severity"` // mild, moderate or severe
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"index"` // sync cursor
+}
+
+// Reaction severities of a food introduction
+const (
+	ReactionNone     = "none"
+	ReactionMild     = "mild"
+	ReactionModerate = "moderate"
+	ReactionSevere   = "severe"
+)
+
+// FoodIntroduction is a solid food given to a baby, with any reaction to it.
+type FoodIntroduction struct {
+	ID               string    `json:"id" gorm:"primaryKey"`
+	Time             time.Time `json:"time"`
+	Food             string    `json:"food"`
+	AllergenCategory string    `json:"allergenCategory"` // empty when the food isn't a common allergen
+	Amount           string    `json:"amount"`           // free text, e.g. "1 tsp"
+	ReactionSeverity string    `json:"reactionSeverity" gorm:"not null;default:none"`
+	Reaction         string    `json:"reaction"` // what was observed, e.g. hives, vomiting
+	BabyID           string    `json:"babyId" gorm:"index"`
+	Note             string    `json:"note"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt" gorm:"index"` // sync cursor
+}
+
+// Milestone is a developmental milestone, e.g. first smile or first steps.
+type Milestone struct {
+	ID          string       `json:"id" gorm:"primaryKey"`
+	BabyID      string       `json:"babyId" gorm:"index"`
+	Date        time.Time    `json:"date"`
+	Title       string       `json:"title"`
+	Category    string       `json:"category"` // motor, social, language or cognitive
+	Note        string       `json:"note"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	UpdatedAt   time.Time    `json:"updatedAt" gorm:"index"` // sync cursor
+	Attachments []Attachment `json:"attachments" gorm:"polymorphic:Owner"`
+}
+
+// Attachment is a file uploaded for a milestone, sleep, diaper or nursing.
+// OwnerType is the table name of the record it belongs to.
+type Attachment struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	BabyID      string    `json:"babyId" gorm:"index"`
+	OwnerType   string    `json:"ownerType" gorm:"index:idx_attachment_owner"`
+	OwnerID     string    `json:"ownerId" gorm:"index:idx_attachment_owner"`
+	FileName    string    `json:"fileName"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Field types of a custom event
+const (
+	FieldDuration = "duration" // minutes
+	FieldNumber   = "number"
+	FieldEnum     = "enum"
+	FieldText     = "text"
+)
+
+// EventField describes a value recorded with every event of a custom type.
+type EventField struct {
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"` // allowed values of an enum field
+	Min      *float64 `json:"min,omitempty"`     // bounds of a number field
+	Max      *float64 `json:"max,omitempty"`
+	Unit     string   `json:"unit,omitempty"`
+}
+
+// EventFields is stored as a JSON column.
+type EventFields []EventField
+
+func (f EventFields) Value() (driver.Value, error) {
+	return json.Marshal(f)
+}
+
+func (f *EventFields) Scan(value interface{}) error {
+	return scanJSON(value, f)
+}
+
+func (EventFields) GormDataType() string {
+	return "jsonb"
+}
+
+// EventValues holds the field values of a custom event by field key. It is
+// stored as a JSON column.
+type EventValues map[string]interface{}
+
+func (v EventValues) Value() (driver.Value, error) {
+	return json.Marshal(v)
+}
+
+func (v *EventValues) Scan(value interface{}) error {
+	return scanJSON(value, v)
+}
+
+func (EventValues) GormDataType() string {
+	return "jsonb"
+}
+
+func scanJSON(value interface{}, dest interface{}) error {
+	switch raw := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(raw, dest)
+	case string:
+		return json.Unmarshal([]byte(raw), dest)
+	}
+	return errors.New("unsupported JSON column value")
+}
+
+// EventType is a kind of event defined by the parents of a baby, e.g. tummy
+// time or baths.
+type EventType struct {
+	ID        string      `json:"id" gorm:"primaryKey"`
+	BabyID    string      `json:"babyId" gorm:"index"`
+	Name      string      `json:"name"`
+	Icon      string      `json:"icon"` // emoji or icon name, up to the client
+	Fields    EventFields `json:"fields"`
+	CreatedAt time.Time   `json:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt" gorm:"index"` // sync cursor
+}
+
+// CustomEvent is an event of a user-defined type.
+type CustomEvent struct {
+	ID          string      `json:"id" gorm:"primaryKey"`
+	EventTypeID string      `json:"eventTypeId" gorm:"index"`
+	BabyID      string      `json:"babyId" gorm:"index"`
+	Time        time.Time   `json:"time"`
+	Values      EventValues `json:"values"`
+	Note        string      `json:"note"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt" gorm:"index"` // sync cursor
+}
+
+// Tombstone records the deletion of an event so that syncing clients can
+// remove their copy. EntityType is the table name of the deleted record.
+type Tombstone struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	BabyID     string    `json:"babyId" gorm:"index:idx_tombstone_baby_deleted"`
+	EntityType string    `json:"entityType"`
+	EntityID   string    `json:"entityId"`
+	DeletedAt  time.Time `json:"deletedAt" gorm:"index:idx_tombstone_baby_deleted"`
+}
+
+// Vaccination is a vaccine dose a baby received.
+type Vaccination struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	BabyID     string    `json:"babyId" gorm:"index"`
+	Vaccine    string    `json:"vaccine"` // as named in the baby's vaccine schedule
+	DoseNumber int       `json:"doseNumber"`
+	Date       time.Time `json:"date"`
+	LotNumber  string    `json:"lotNumber"`
+	Clinic     string    `json:"clinic"`
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt" gorm:"index"` // sync cursor
+}
+
+// Measurement is a growth measurement. Any of the values may be missing when
+// only some of them were taken.
+type Measurement struct {
+	ID                  string    `json:"id" gorm:"primaryKey"`
+	Time                time.Time `json:"time"`
+	WeightKg            *float64  `json:"weightKg"`
+	LengthCm            *float64  `json:"lengthCm"`
+	HeadCircumferenceCm *float64  `json:"headCircumferenceCm"`
+	BabyID              string    `json:"babyId"`
+	Note                string    `json:"note"`
+	CreatedAt           time.Time `json:"createdAt"`
+	UpdatedAt           time.Time `json:"updatedAt" gorm:"index"` // sync cursor
+}
+
+// Roles a user can hold on a baby, from most to least privileged.
+const (
+	RoleOwner     = "owner"     // full access, including sharing and membership
+	RoleCaregiver = "caregiver" // can log and edit events
+	RoleViewer    = "viewer"    // read-only
+)
+
+// UserBaby is a user's membership of a baby, stored in the user_babies join
+// table behind User.Babies and Baby.Parents.
+type UserBaby struct {
+	UserID    string    `json:"userId" gorm:"primaryKey"`
+	BabyID    string    `json:"babyId" gorm:"primaryKey"`
+	Role      string    `json:"role" gorm:"not null;default:owner"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Invitation lets a baby's owner invite someone to join it. Codes are single
+// use and expire.
+type Invitation struct {
+	ID           string     `json:"id" gorm:"primaryKey"`
+	Code         string     `json:"code" gorm:"uniqueIndex;not null"`
+	BabyID       string     `json:"babyId" gorm:"index"`
+	Role         string     `json:"role" gorm:"not null"`
+	Username     string     `json:"username,omitempty"` // when set, only this user can accept
+	CreatedByID  string     `json:"createdById"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+	AcceptedByID *string    `json:"acceptedById,omitempty"`
+	AcceptedAt   *time.Time `json:"acceptedAt,omitempty"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Session is a signed-in device. Access tokens carry the session ID so that
+// revoking a session also invalidates its outstanding access tokens.
+type Session struct {
+	ID                string     `json:"id" gorm:"primaryKey"`
+	UserID            string     `json:"-" gorm:"index;not null"`
+	RefreshTokenHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	PreviousTokenHash string     `json:"-" gorm:"index"` // last rotated-out refresh token, to detect reuse
+	DeviceName        string     `json:"deviceName"`
+	UserAgent         string     `json:"userAgent"`
+	IP                string     `json:"ip"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	LastUsedAt        time.Time  `json:"lastUsedAt"`
+	ExpiresAt         time.Time  `json:"expiresAt"`
+	RevokedAt         *time.Time `json:"revokedAt,omitempty"`
 }
 
 type User struct {
-	ID       string `json:"id" gorm:"primaryKey"`
-	Username string `json:"username" gorm:"unique;not null"`
-	Password string `json:"-" gorm:"not null"` // "-" means this field won't be included in JSON
-	Babies   []Baby `json:"babies,omitempty" gorm:"many2many:user_babies"`
+	ID                 string `json:"id" gorm:"primaryKey"`
+	Username           string `json:"username" gorm:"unique;not null"`
+	Password           string `json:"-" gorm:"not null"`               // "-" means this field won't be included in JSON
+	MustChangePassword bool   `json:"-" gorm:"not null;default:false"` // only shown to the user themselves
+	Babies             []Baby `json:"babies,omitempty" gorm:"many2many:user_babies"`
 }
 
+// PasswordResetToken is a single-use token an admin issues so a user who lost
+// their password can set a new one.
+type PasswordResetToken struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	UserID      string     `json:"userId" gorm:"index;not null"`
+	TokenHash   string     `json:"-" gorm:"uniqueIndex;not null"`
+	CreatedByID string     `json:"createdById"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	UsedAt      *time.Time `json:"usedAt,omitempty"`
+}
+
+// Defaults applied to babies that don't configure their own report day and
+// night hours. They match the boundaries the reports used before these
+// settings existed.
+const (
+	DefaultTimezone        = "Europe/Paris"
+	DefaultDayStartHour    = 1
+	DefaultVaccineSchedule = "fr"
+	DefaultNightStartHour  = 19
+	DefaultNightEndHour    = 7
+)
+
 type Baby struct {
-	ID         string    `json:"id" gorm:"primaryKey"`
-	Name       string    `json:"name"`
-	ShareToken string    `json:"shareToken,omitempty" gorm:"unique"`
-	Parents    []User    `json:"parents,omitempty" gorm:"many2many:user_babies"`
-	Nursings   []Nursing `json:"nursings,omitempty" gorm:"foreignKey:BabyID"`
-	Diapers    []Diaper  `json:"diapers,omitempty" gorm:"foreignKey:BabyID"`
-	Sleeps     []Sleep   `json:"sleeps,omitempty" gorm:"foreignKey:BabyID"`
+	ID                 string        `json:"id" gorm:"primaryKey"`
+	Name               string        `json:"name"`
+	ShareToken         string        `json:"shareToken,omitempty" gorm:"unique"`
+	Timezone           string        `json:"timezone" gorm:"not null"`       // IANA name, e.g. "America/New_York"
+	DayStartHour       int           `json:"dayStartHour" gorm:"not null"`   // local hour (0-23) at which a report day starts
+	NightStartHour     int           `json:"nightStartHour" gorm:"not null"` // local hours (0-23) between which sleep counts as night sleep
+	NightEndHour       int           `json:"nightEndHour" gorm:"not null"`
+	BirthDate          *time.Time    `json:"birthDate" gorm:"type:timestamptz"`
+	Sex                string        `json:"sex"` // "male" or "female", used for growth percentiles
+	BirthWeightKg      *float64      `json:"birthWeightKg"`
+	BirthLengthCm      *float64      `json:"birthLengthCm"`
+	GestationalAgeDays *int          `json:"gestationalAgeDays"` // at birth, e.g. 36 weeks 3 days is 255; used for corrected age
+	AvatarKey          string        `json:"-"`                  // blob storage key of the avatar picture
+	AvatarContentType  string        `json:"-"`
+	AvatarUpdatedAt    *time.Time    `json:"avatarUpdatedAt"`                            // nil when the baby has no avatar
+	Archived           bool          `json:"archived" gorm:"not null;default:false"`     // hidden from the baby list, history is kept
+	VaccineSchedule    string        `json:"vaccineSchedule" gorm:"not null;default:fr"` // country code of the national schedule
+	Role               string        `json:"role,omitempty" gorm:"-"`                    // the requesting user's role, filled in by the API
+	Parents            []User        `json:"parents,omitempty" gorm:"many2many:user_babies"`
+	Nursings           []Nursing     `json:"nursings,omitempty" gorm:"foreignKey:BabyID"`
+	Diapers            []Diaper      `json:"diapers,omitempty" gorm:"foreignKey:BabyID"`
+	Sleeps             []Sleep       `json:"sleeps,omitempty" gorm:"foreignKey:BabyID"`
+	Measurements       []Measurement `json:"measurements,omitempty" gorm:"foreignKey:BabyID"`
+	Vaccinations       []Vaccination `json:"vaccinations,omitempty" gorm:"foreignKey:BabyID"`
 }
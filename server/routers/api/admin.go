@@ -0,0 +1,80 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// isAdmin reports whether the user is listed in the comma separated
+// ADMIN_USERNAMES environment variable.
+func isAdmin(user models.User) bool {
+	for _, username := range strings.Split(os.Getenv("ADMIN_USERNAMES"), ",") {
+		if username = strings.TrimSpace(username); username != "" && username == user.Username {
+			return true
+		}
+	}
+	return false
+}
+
+func requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userInterface, _ := c.Get("user")
+		if !isAdmin(userInterface.(models.User)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func SetupAdminRoutes(api *gin.RouterGroup) {
+	admin := api.Group("/admin")
+	admin.Use(AuthMiddleware(), requireAdmin())
+	{
+		// POST /api/admin/users/:username/reset-token - Issue a password reset token
+		admin.POST("/users/:username/reset-token", func(c *gin.Context) {
+			userInterface, _ := c.Get("user")
+			adminUser := userInterface.(models.User)
+
+			var user models.User
+			if err := database.DB.First(&user, "username = ?", c.Param("username")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+
+			token, err := generateRefreshToken()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+				return
+			}
+
+			resetToken := models.PasswordResetToken{
+				ID:          uuid.NewString(),
+				UserID:      user.ID,
+				TokenHash:   hashToken(token),
+				CreatedByID: adminUser.ID,
+				ExpiresAt:   time.Now().Add(passwordResetTTL).UTC(),
+			}
+			if err := database.DB.Create(&resetToken).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			// The token is only ever shown here; hand it to the user out of band
+			c.JSON(http.StatusOK, gin.H{
+				"token":     token,
+				"userId":    user.ID,
+				"username":  user.Username,
+				"expiresAt": resetToken.ExpiresAt,
+			})
+		})
+	}
+}
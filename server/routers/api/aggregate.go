@@ -0,0 +1,147 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"math"
+	"time"
+)
+
+// Granularities of a range report
+const (
+	granularityDay   = "day"
+	granularityWeek  = "week"
+	granularityMonth = "month"
+)
+
+// granularitySteps are the bucket sizes of each granularity, as intervals.
+var granularitySteps = map[string]string{
+	granularityDay:   "1 day",
+	granularityWeek:  "1 week",
+	granularityMonth: "1 month",
+}
+
+// PeriodSummary aggregates a baby's events over a bucket of report days.
+type PeriodSummary struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	Days            int       `json:"days"` // report days in the bucket
+	TotalHoursSlept float64   `json:"totalHoursSlept"`
+	DiaperCount     int       `json:"diaperCount"`
+	NursingCount    int       `json:"nursingCount"` // feeds only, pumping sessions excluded
+	IntakeML        float64   `json:"intakeMl"`
+	// Average time between the starts of consecutive feeds, nil with less
+	// than two feeds
+	AvgFeedIntervalMinutes *float64 `json:"avgFeedIntervalMinutes"`
+}
+
+// aggregateQuery summarises events per bucket. Buckets are built from the
+// local wall clock like babyDayBounds, so they follow DST changes, and the
+// first and last are clipped to the range. Sleeps are clipped to the bucket
+// they overlap, a running sleep counting up to now. A feed interval belongs
+// to the bucket of the feed ending it, looking back one day for the first.
+const aggregateQuery = `
+WITH buckets AS (
+	SELECT GREATEST((d + make_interval(hours => CAST(@hour AS int))) AT TIME ZONE @tz, @rangeStart) AS bucket_start,
+		LEAST((d + CAST(@step AS interval) + make_interval(hours => CAST(@hour AS int))) AT TIME ZONE @tz, @rangeEnd) AS bucket_end
+	FROM generate_series(CAST(@first AS timestamp), CAST(@last AS timestamp), CAST(@step AS interval)) AS d
+),
+feeds AS (
+	SELECT time, time - lag(time) OVER (ORDER BY time) AS gap
+	FROM nursings
+	WHERE baby_id = @babyID AND deleted_at IS NULL AND kind <> @pumping
+		AND time >= CAST(@rangeStart AS timestamptz) - interval '1 day' AND time < @rangeEnd
+)
+SELECT b.bucket_start, b.bucket_end,
+	COALESCE(s.seconds, 0) AS sleep_seconds,
+	COALESCE(di.count, 0) AS diaper_count,
+	COALESCE(n.feeds, 0) AS feed_count,
+	COALESCE(n.intake, 0) AS intake_ml,
+	fi.seconds AS feed_interval_seconds
+FROM buckets b
+LEFT JOIN LATERAL (
+	SELECT SUM(EXTRACT(EPOCH FROM LEAST(COALESCE(sleeps."end", @now), b.bucket_end) - GREATEST(sleeps.start, b.bucket_start))) AS seconds
+	FROM sleeps
+	WHERE sleeps.baby_id = @babyID AND sleeps.deleted_at IS NULL
+		AND sleeps.start < b.bucket_end AND COALESCE(sleeps."end", @now) > b.bucket_start
+) s ON true
+LEFT JOIN LATERAL (
+	SELECT COUNT(*) AS count
+	FROM diapers
+	WHERE diapers.baby_id = @babyID AND diapers.deleted_at IS NULL
+		AND diapers.time >= b.bucket_start AND diapers.time < b.bucket_end
+) di ON true
+LEFT JOIN LATERAL (
+	SELECT COUNT(*) FILTER (WHERE nursings.kind <> @pumping) AS feeds,
+		SUM(nursings.volume_ml) FILTER (WHERE nursings.kind IN (@bottleBreastMilk, @bottleFormula)) AS intake
+	FROM nursings
+	WHERE nursings.baby_id = @babyID AND nursings.deleted_at IS NULL
+		AND nursings.time >= b.bucket_start AND nursings.time < b.bucket_end
+) n ON true
+LEFT JOIN LATERAL (
+	SELECT AVG(EXTRACT(EPOCH FROM feeds.gap)) AS seconds
+	FROM feeds
+	WHERE feeds.gap IS NOT NULL AND feeds.time >= b.bucket_start AND feeds.time < b.bucket_end
+) fi ON true
+WHERE b.bucket_start < b.bucket_end
+ORDER BY b.bucket_start`
+
+// aggregateEvents summarises the baby's events between the report days from
+// and to, both included, per bucket of the granularity. Weeks start on
+// Monday. It runs a single query whatever the length of the range.
+func aggregateEvents(baby models.Baby, from, to time.Time, granularity string) ([]PeriodSummary, error) {
+	first := from
+	switch granularity {
+	case granularityWeek:
+		first = from.AddDate(0, 0, -(int(from.Weekday())+6)%7)
+	case granularityMonth:
+		first = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	rangeStart, _ := babyDayBounds(baby, from)
+	_, rangeEnd := babyDayBounds(baby, to)
+	loc := babyLocation(baby)
+
+	var rows []struct {
+		BucketStart         time.Time
+		BucketEnd           time.Time
+		SleepSeconds        float64
+		DiaperCount         int
+		FeedCount           int
+		IntakeML            float64
+		FeedIntervalSeconds *float64
+	}
+	if err := database.DB.Raw(aggregateQuery, map[string]interface{}{
+		"babyID":           baby.ID,
+		"tz":               loc.String(),
+		"hour":             baby.DayStartHour,
+		"step":             granularitySteps[granularity],
+		"first":            first.Format("2006-01-02"),
+		"last":             to.Format("2006-01-02"),
+		"rangeStart":       rangeStart,
+		"rangeEnd":         rangeEnd,
+		"now":              time.Now(),
+		"pumping":          models.FeedingPumping,
+		"bottleBreastMilk": models.FeedingBottleBreastMilk,
+		"bottleFormula":    models.FeedingBottleFormula,
+	}).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	periods := make([]PeriodSummary, len(rows))
+	for i, row := range rows {
+		periods[i] = PeriodSummary{
+			Start:           row.BucketStart.In(loc),
+			End:             row.BucketEnd.In(loc),
+			Days:            int(math.Round(row.BucketEnd.Sub(row.BucketStart).Hours() / 24)),
+			TotalHoursSlept: row.SleepSeconds / 3600,
+			DiaperCount:     row.DiaperCount,
+			NursingCount:    row.FeedCount,
+			IntakeML:        row.IntakeML,
+		}
+		if row.FeedIntervalSeconds != nil {
+			minutes := *row.FeedIntervalSeconds / 60
+			periods[i].AvgFeedIntervalMinutes = &minutes
+		}
+	}
+	return periods, nil
+}
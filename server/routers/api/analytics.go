@@ -0,0 +1,602 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Kinds of sleeps and of the gaps between them
+const (
+	sleepNap   = "nap"
+	sleepNight = "night"
+)
+
+// SleepAnalytics breaks a range of report days down into naps and night
+// sleep, following the baby's night hours.
+type SleepAnalytics struct {
+	StartDate      time.Time  `json:"startDate"`
+	EndDate        time.Time  `json:"endDate"`
+	NightStartHour int        `json:"nightStartHour"`
+	NightEndHour   int        `json:"nightEndHour"`
+	Days           []SleepDay `json:"days"` // oldest first
+}
+
+// SleepDay holds the sleep analytics of a report day. Sleeps count toward the
+// day in which they start, like in the daily report, so that a night isn't
+// cut in two. Wakings and wake windows count toward the day of the sleep that
+// ends them.
+type SleepDay struct {
+	Date                  time.Time         `json:"date"`
+	TotalHours            float64           `json:"totalHours"`
+	NightHours            float64           `json:"nightHours"`
+	NapHours              float64           `json:"napHours"`
+	NapCount              int               `json:"napCount"`
+	LongestStretchMinutes float64           `json:"longestStretchMinutes"` // longest single sleep
+	NightWakings          int               `json:"nightWakings"`
+	WakeWindowsMinutes    []float64         `json:"wakeWindowsMinutes"`
+	AvgWakeWindowMinutes  *float64          `json:"avgWakeWindowMinutes"` // nil without any wake window
+	Sleeps                []ClassifiedSleep `json:"sleeps"`
+}
+
+// ClassifiedSleep is a sleep with its kind, nap or night.
+type ClassifiedSleep struct {
+	ID         string     `json:"id"`
+	Start      time.Time  `json:"start"`
+	End        *time.Time `json:"end"`
+	InProgress bool       `json:"inProgress"`
+	Minutes    float64    `json:"minutes"` // up to now while in progress
+	Kind       string     `json:"kind"`
+}
+
+// nightOverlap returns how much of the time between from and to falls within
+// the baby's night hours, in its timezone.
+func nightOverlap(baby models.Baby, from, to time.Time) time.Duration {
+	loc := babyLocation(baby)
+	first := from.In(loc).AddDate(0, 0, -1)
+	var overlap time.Duration
+	for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		start := time.Date(day.Year(), day.Month(), day.Day(), baby.NightStartHour, 0, 0, 0, loc)
+		end := time.Date(day.Year(), day.Month(), day.Day(), baby.NightEndHour, 0, 0, 0, loc)
+		if baby.NightEndHour < baby.NightStartHour {
+			// The night ends on the next day
+			end = time.Date(day.Year(), day.Month(), day.Day()+1, baby.NightEndHour, 0, 0, 0, loc)
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			overlap += end.Sub(start)
+		}
+	}
+	return overlap
+}
+
+// sleepKind classifies the time between from and to as night when at least
+// half of it falls within the night hours, as a nap otherwise.
+func sleepKind(baby models.Baby, from, to time.Time) string {
+	if 2*nightOverlap(baby, from, to) >= to.Sub(from) {
+		return sleepNight
+	}
+	return sleepNap
+}
+
+// analyzeSleeps computes the sleep analytics of the report days from from to
+// to, both included.
+func analyzeSleeps(baby models.Baby, from, to time.Time) (SleepAnalytics, error) {
+	rangeStart, _ := babyDayBounds(baby, from)
+	_, rangeEnd := babyDayBounds(baby, to)
+
+	// The day before gives the sleep ending the first wake window
+	var sleeps []models.Sleep
+	if err := database.DB.Where("baby_id = ? AND start >= ? AND start < ?",
+		baby.ID, rangeStart.AddDate(0, 0, -1), rangeEnd).Order("start").Find(&sleeps).Error; err != nil {
+		return SleepAnalytics{}, err
+	}
+
+	analytics := SleepAnalytics{
+		StartDate:      rangeStart,
+		EndDate:        rangeEnd,
+		NightStartHour: baby.NightStartHour,
+		NightEndHour:   baby.NightEndHour,
+		Days:           []SleepDay{},
+	}
+	var dayStarts []time.Time
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		start, _ := babyDayBounds(baby, date)
+		dayStarts = append(dayStarts, start)
+		analytics.Days = append(analytics.Days, SleepDay{
+			Date:               start,
+			WakeWindowsMinutes: []float64{},
+			Sleeps:             []ClassifiedSleep{},
+		})
+	}
+	// dayOf returns the day containing t, nil out of the range
+	dayOf := func(t time.Time) *SleepDay {
+		if t.Before(rangeStart) || !t.Before(rangeEnd) {
+			return nil
+		}
+		i := len(dayStarts) - 1
+		for dayStarts[i].After(t) {
+			i--
+		}
+		return &analytics.Days[i]
+	}
+
+	now := time.Now()
+	var previousEnd *time.Time
+	for _, sleep := range sleeps {
+		end := sleepEnd(sleep, now)
+		if day := dayOf(sleep.Start); day != nil {
+			kind := sleepKind(baby, sleep.Start, end)
+			minutes := end.Sub(sleep.Start).Minutes()
+			day.Sleeps = append(day.Sleeps, ClassifiedSleep{
+				ID:         sleep.ID,
+				Start:      sleep.Start,
+				End:        sleep.End,
+				InProgress: sleep.InProgress,
+				Minutes:    minutes,
+				Kind:       kind,
+			})
+			day.TotalHours += minutes / 60
+			if kind == sleepNight {
+				day.NightHours += minutes / 60
+			} else {
+				day.NapHours += minutes / 60
+				day.NapCount++
+			}
+			if minutes > day.LongestStretchMinutes {
+				day.LongestStretchMinutes = minutes
+			}
+
+			// Time awake since the previous sleep, skipping overlapping entries
+			if previousEnd != nil && sleep.Start.After(*previousEnd) {
+				if sleepKind(baby, *previousEnd, sleep.Start) == sleepNight {
+					day.NightWakings++
+				} else {
+					day.WakeWindowsMinutes = append(day.WakeWindowsMinutes, sleep.Start.Sub(*previousEnd).Minutes())
+				}
+			}
+		}
+		if previousEnd == nil || end.After(*previousEnd) {
+			previousEnd = &end
+		}
+	}
+
+	for i := range analytics.Days {
+		day := &analytics.Days[i]
+		if len(day.WakeWindowsMinutes) == 0 {
+			continue
+		}
+		var total float64
+		for _, minutes := range day.WakeWindowsMinutes {
+			total += minutes
+		}
+		average := total / float64(len(day.WakeWindowsMinutes))
+		day.AvgWakeWindowMinutes = &average
+	}
+
+	return analytics, nil
+}
+
+// Cluster feeding is a run of at least clusterMinFeeds feeds, each starting
+// at most clusterMaxGap after the previous one.
+const (
+	clusterMinFeeds = 3
+	clusterMaxGap   = time.Hour
+)
+
+// FeedingAnalytics sums up the feeds of a range of report days. Pumping
+// sessions aren't feeds and are left out.
+type FeedingAnalytics struct {
+	StartDate          time.Time   `json:"startDate"`
+	EndDate            time.Time   `json:"endDate"`
+	FeedCount          int         `json:"feedCount"`
+	AvgIntervalMinutes *float64    `json:"avgIntervalMinutes"` // between the starts of consecutive feeds, nil with less than two
+	MaxIntervalMinutes *float64    `json:"maxIntervalMinutes"`
+	Sides              SideBalance `json:"sides"`
+	// Last breast feed, even after the range, and the side to start the next
+	// one on
+	LastBreastFeedAt *time.Time    `json:"lastBreastFeedAt"`
+	LastSide         string        `json:"lastSide,omitempty"`
+	NextSide         string        `json:"nextSide,omitempty"`
+	ClusterFeeds     []ClusterFeed `json:"clusterFeeds"`
+}
+
+// SideBalance counts the breast feeds per side. Feeds on both sides count
+// half for each in LeftShare, and so do their minutes.
+type SideBalance struct {
+	Left         int      `json:"left"`
+	Right        int      `json:"right"`
+	Both         int      `json:"both"`
+	LeftMinutes  float64  `json:"leftMinutes"` // of the feeds recorded with the timer
+	RightMinutes float64  `json:"rightMinutes"`
+	LeftShare    *float64 `json:"leftShare"` // between 0 and 1, nil without breast feeds
+}
+
+// ClusterFeed is a period of cluster feeding.
+type ClusterFeed struct {
+	Start     time.Time `json:"start"` // of the first feed
+	End       time.Time `json:"end"`   // of the last feed, its start when it has no end
+	FeedCount int       `json:"feedCount"`
+}
+
+// nextBreastSide returns the side to start the next breast feed on: the
+// other side after a one-sided feed, the least used side of the balance after
+// a feed on both sides.
+func nextBreastSide(last models.Nursing, balance SideBalance) string {
+	switch last.Type {
+	case models.SideLeft:
+		return models.SideRight
+	case models.SideRight:
+		return models.SideLeft
+	}
+	if balance.LeftShare != nil && *balance.LeftShare > 0.5 {
+		return models.SideRight
+	}
+	return models.SideLeft
+}
+
+// analyzeFeeds computes the feeding analytics of the report days from from
+// to to, both included.
+func analyzeFeeds(baby models.Baby, from, to time.Time) (FeedingAnalytics, error) {
+	rangeStart, _ := babyDayBounds(baby, from)
+	_, rangeEnd := babyDayBounds(baby, to)
+
+	// The day before gives the feed starting the first interval
+	var nursings []models.Nursing
+	if err := database.DB.Where("baby_id = ? AND kind <> ? AND time >= ? AND time < ?",
+		baby.ID, models.FeedingPumping, rangeStart.AddDate(0, 0, -1), rangeEnd).Order("time").Find(&nursings).Error; err != nil {
+		return FeedingAnalytics{}, err
+	}
+
+	analytics := FeedingAnalytics{
+		StartDate:    rangeStart,
+		EndDate:      rangeEnd,
+		ClusterFeeds: []ClusterFeed{},
+	}
+
+	var previous *models.Nursing
+	var totalInterval, maxInterval time.Duration
+	var intervals int
+	var cluster []models.Nursing
+	flushCluster := func() {
+		if len(cluster) >= clusterMinFeeds {
+			last := cluster[len(cluster)-1]
+			end := last.Time
+			if last.End != nil {
+				end = *last.End
+			}
+			analytics.ClusterFeeds = append(analytics.ClusterFeeds, ClusterFeed{
+				Start:     cluster[0].Time,
+				End:       end,
+				FeedCount: len(cluster),
+			})
+		}
+		cluster = nil
+	}
+	var leftHalves, breastHalves int
+	for i := range nursings {
+		nursing := nursings[i]
+		if nursing.Time.Before(rangeStart) {
+			previous = &nursings[i]
+			continue
+		}
+
+		analytics.FeedCount++
+		if previous != nil {
+			interval := nursing.Time.Sub(previous.Time)
+			totalInterval += interval
+			intervals++
+			if interval > maxInterval {
+				maxInterval = interval
+			}
+		}
+		if previous == nil || nursing.Time.Sub(previous.Time) > clusterMaxGap {
+			flushCluster()
+		}
+		cluster = append(cluster, nursing)
+		previous = &nursings[i]
+
+		if nursing.Kind != models.FeedingBreast {
+			continue
+		}
+		var minutes float64
+		if nursing.End != nil {
+			minutes = nursing.End.Sub(nursing.Time).Minutes()
+		}
+		switch nursing.Type {
+		case models.SideLeft:
+			analytics.Sides.Left++
+			analytics.Sides.LeftMinutes += minutes
+			leftHalves += 2
+			breastHalves += 2
+		case models.SideRight:
+			analytics.Sides.Right++
+			analytics.Sides.RightMinutes += minutes
+			breastHalves += 2
+		case models.SideBoth:
+			analytics.Sides.Both++
+			analytics.Sides.LeftMinutes += minutes / 2
+			analytics.Sides.RightMinutes += minutes / 2
+			leftHalves++
+			breastHalves += 2
+		}
+	}
+	flushCluster()
+
+	if intervals > 0 {
+		average := totalInterval.Minutes() / float64(intervals)
+		longest := maxInterval.Minutes()
+		analytics.AvgIntervalMinutes = &average
+		analytics.MaxIntervalMinutes = &longest
+	}
+	if breastHalves > 0 {
+		share := float64(leftHalves) / float64(breastHalves)
+		analytics.Sides.LeftShare = &share
+	}
+
+	// The next side follows the very last breast feed, whatever the range
+	var last models.Nursing
+	err := database.DB.Where("baby_id = ? AND kind = ? AND type IN ?",
+		baby.ID, models.FeedingBreast, []string{models.SideLeft, models.SideRight, models.SideBoth}).
+		Order("time DESC").Limit(1).Find(&last).Error
+	if err != nil {
+		return FeedingAnalytics{}, err
+	}
+	if last.ID != "" {
+		analytics.LastBreastFeedAt = &last.Time
+		analytics.LastSide = last.Type
+		analytics.NextSide = nextBreastSide(last, analytics.Sides)
+	}
+
+	return analytics, nil
+}
+
+// getFeedingAnalytics answers a feeding analytics request for the range of
+// the from and to query parameters.
+func getFeedingAnalytics(c *gin.Context, baby models.Baby) {
+	from, to, ok := parseReportRange(c, baby)
+	if !ok {
+		return
+	}
+	if to.Sub(from).Hours()/24 >= maxTrendDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Analytics cover at most a year"})
+		return
+	}
+
+	result, err := analyzeFeeds(baby, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Feed predictions use the intervals between the last predictionFeeds feeds
+// of the last predictionLookback.
+const (
+	predictionFeeds    = 9
+	predictionLookback = 72 * time.Hour
+)
+
+// Bases of a prediction
+const (
+	basisFeedIntervals     = "feedIntervals"
+	basisAgeWakeWindow     = "ageWakeWindow"
+	basisRecentWakeWindows = "recentWakeWindows"
+)
+
+// wakeWindows are the usual time awake between sleeps, by age in weeks up
+// to which they apply.
+var wakeWindows = []struct {
+	untilWeeks int
+	min, max   time.Duration
+}{
+	{4, 35 * time.Minute, 60 * time.Minute},
+	{12, 60 * time.Minute, 90 * time.Minute},
+	{17, 75 * time.Minute, 2 * time.Hour},
+	{30, 2 * time.Hour, 3 * time.Hour},
+	{43, 150 * time.Minute, 210 * time.Minute},
+	{61, 3 * time.Hour, 4 * time.Hour},
+	{104, 4 * time.Hour, 6 * time.Hour},
+}
+
+// Prediction is when something is likely to happen next, with the range in
+// which it most likely will.
+type Prediction struct {
+	Estimate time.Time `json:"estimate"`
+	Earliest time.Time `json:"earliest"`
+	Latest   time.Time `json:"latest"`
+	Overdue  bool      `json:"overdue"` // the estimate is already past
+	Basis    string    `json:"basis"`   // what the estimate is derived from
+}
+
+// Predictions are the baby's next feed and nap as of now.
+type Predictions struct {
+	NextFeed *Prediction `json:"nextFeed"` // nil without two recent feeds
+	NextNap  *Prediction `json:"nextNap"`  // nil while asleep or without a sleep to start from
+	Asleep   bool        `json:"asleep"`
+}
+
+func newPrediction(from time.Time, estimate, earliest, latest time.Duration, basis string, now time.Time) *Prediction {
+	return &Prediction{
+		Estimate: from.Add(estimate),
+		Earliest: from.Add(earliest),
+		Latest:   from.Add(latest),
+		Overdue:  from.Add(estimate).Before(now),
+		Basis:    basis,
+	}
+}
+
+// percentile returns the p-th percentile of sorted values, interpolating
+// between the closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+func minutesDuration(minutes float64) time.Duration {
+	return time.Duration(minutes * float64(time.Minute))
+}
+
+// predictNextFeed estimates the next feed from the median of the recent
+// intervals between feeds, within their interquartile range.
+func predictNextFeed(baby models.Baby, now time.Time) (*Prediction, error) {
+	var feeds []models.Nursing
+	if err := database.DB.Where("baby_id = ? AND kind <> ? AND time >= ? AND time <= ?",
+		baby.ID, models.FeedingPumping, now.Add(-predictionLookback), now).
+		Order("time DESC").Limit(predictionFeeds).Find(&feeds).Error; err != nil {
+		return nil, err
+	}
+	if len(feeds) < 2 {
+		return nil, nil
+	}
+
+	intervals := make([]float64, 0, len(feeds)-1)
+	for i := 1; i < len(feeds); i++ {
+		intervals = append(intervals, feeds[i-1].Time.Sub(feeds[i].Time).Minutes())
+	}
+	sort.Float64s(intervals)
+
+	return newPrediction(feeds[0].Time, minutesDuration(percentile(intervals, 0.5)),
+		minutesDuration(percentile(intervals, 0.25)), minutesDuration(percentile(intervals, 0.75)), basisFeedIntervals, now), nil
+}
+
+// predictNextNap estimates the next sleep from the end of the last one and
+// the usual wake window at the baby's age, corrected for premature babies.
+// Without a birth date, or past the ages of wakeWindows, it uses the baby's
+// wake windows of the last days instead.
+func predictNextNap(baby models.Baby, now time.Time) (*Prediction, bool, error) {
+	var last models.Sleep
+	if err := database.DB.Where("baby_id = ? AND start <= ?", baby.ID, now).
+		Order("start DESC").Limit(1).Find(&last).Error; err != nil {
+		return nil, false, err
+	}
+	if last.ID == "" {
+		return nil, false, nil
+	}
+	if last.End == nil {
+		return nil, true, nil
+	}
+
+	if age := babyAge(baby, now); age != nil {
+		weeks := age.Weeks
+		if age.CorrectedWeeks != nil {
+			weeks = max(*age.CorrectedWeeks, 0)
+		}
+		for _, window := range wakeWindows {
+			if weeks < window.untilWeeks {
+				return newPrediction(*last.End, (window.min+window.max)/2, window.min, window.max, basisAgeWakeWindow, now), false, nil
+			}
+		}
+	}
+
+	today := babyToday(baby, now)
+	recent, err := analyzeSleeps(baby, today.AddDate(0, 0, -2), today)
+	if err != nil {
+		return nil, false, err
+	}
+	var windows []float64
+	for _, day := range recent.Days {
+		windows = append(windows, day.WakeWindowsMinutes...)
+	}
+	if len(windows) == 0 {
+		return nil, false, nil
+	}
+	sort.Float64s(windows)
+
+	return newPrediction(*last.End, minutesDuration(percentile(windows, 0.5)),
+		minutesDuration(percentile(windows, 0.25)), minutesDuration(percentile(windows, 0.75)), basisRecentWakeWindows, now), false, nil
+}
+
+// getPredictions answers a prediction request for the baby.
+func getPredictions(c *gin.Context, baby models.Baby) {
+	now := time.Now()
+	nextFeed, err := predictNextFeed(baby, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	nextNap, asleep, err := predictNextNap(baby, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, Predictions{
+		NextFeed: nextFeed,
+		NextNap:  nextNap,
+		Asleep:   asleep,
+	})
+}
+
+func SetupAnalyticsRoutes(api *gin.RouterGroup) {
+	analytics := api.Group("/analytics")
+	analytics.Use(AuthMiddleware(), checkBabyParamAccess()) // Every route is about the baby of :id
+	{
+		// GET /api/analytics/:id/sleep?from=&to= - Naps, night sleep, wakings
+		// and wake windows per report day, to defaulting to today
+		analytics.GET("/:id/sleep", func(c *gin.Context) {
+			babyID := c.Param("id")
+
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
+				return
+			}
+
+			from, to, ok := parseReportRange(c, baby)
+			if !ok {
+				return
+			}
+			if to.Sub(from).Hours()/24 >= maxTrendDays {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Analytics cover at most a year"})
+				return
+			}
+
+			result, err := analyzeSleeps(baby, from, to)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, result)
+		})
+
+		// GET /api/analytics/:id/feeding?from=&to= - Feed intervals, side
+		// balance, next side and cluster feeding, to defaulting to today
+		analytics.GET("/:id/feeding", func(c *gin.Context) {
+			babyID := c.Param("id")
+
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
+				return
+			}
+
+			getFeedingAnalytics(c, baby)
+		})
+
+		// GET /api/analytics/:id/prediction - When the next feed and the
+		// next nap are likely
+		analytics.GET("/:id/prediction", func(c *gin.Context) {
+			babyID := c.Param("id")
+
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
+				return
+			}
+
+			getPredictions(c, baby)
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"baby-tracker/storage"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// attachmentOwners maps the owner types accepted by the API to the tables of
+// the records attachments can belong to.
+var attachmentOwners = map[string]string{
+	"milestone": "milestones",
+	"sleep":     "sleeps",
+	"diaper":    "diapers",
+	"nursing":   "nursings",
+}
+
+// maxUploadBytes returns the upload size limit from MAX_UPLOAD_MB, 10 MB by
+// default.
+func maxUploadBytes() int64 {
+	if mb, err := strconv.Atoi(os.Getenv("MAX_UPLOAD_MB")); err == nil && mb > 0 {
+		return int64(mb) << 20
+	}
+	return 10 << 20
+}
+
+func SetupAttachmentRoutes(api *gin.RouterGroup) {
+	attachment := api.Group("/attachment")
+	attachment.Use(AuthMiddleware()) // Add authentication middleware
+	{
+		// POST /api/attachment - Upload a photo as multipart form data with
+		// ownerType, ownerId and file fields
+		attachment.POST("", func(c *gin.Context) {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBytes())
+
+			ownerType, ok := attachmentOwners[c.PostForm("ownerType")]
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ownerType must be milestone, sleep, diaper or nursing"})
+				return
+			}
+			ownerID := c.PostForm("ownerId")
+
+			babyID, err := ownerBabyID(ownerType, ownerID)
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Owner not found"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			if !requireBabyAccess(c, babyID) {
+				return
+			}
+
+			fileHeader, err := c.FormFile("file")
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "File not provided or too large"})
+				return
+			}
+
+			file, err := fileHeader.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			defer file.Close()
+
+			contentType, err := sniffImage(file)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if contentType == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Only PNG, JPEG, GIF and WebP images can be attached"})
+				return
+			}
+
+			attachment := models.Attachment{
+				ID:          uuid.NewString(),
+				BabyID:      babyID,
+				OwnerType:   ownerType,
+				OwnerID:     ownerID,
+				FileName:    filepath.Base(fileHeader.Filename),
+				ContentType: contentType,
+			}
+			attachment.StorageKey = babyID + "/" + attachment.ID
+
+			attachment.Size, err = storage.Store.Put(attachment.StorageKey, file)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+				return
+			}
+
+			if err := database.DB.Create(&attachment).Error; err != nil {
+				removeBlobs([]models.Attachment{attachment})
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, attachment)
+		})
+
+		// GET /api/attachment?ownerType=&ownerId= - List the attachments of a record
+		attachment.GET("", func(c *gin.Context) {
+			ownerType, ok := attachmentOwners[c.Query("ownerType")]
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ownerType must be milestone, sleep, diaper or nursing"})
+				return
+			}
+			ownerID := c.Query("ownerId")
+
+			babyID, err := ownerBabyID(ownerType, ownerID)
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Owner not found"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			if !requireBabyAccess(c, babyID) {
+				return
+			}
+
+			var attachments []models.Attachment
+			if err := database.DB.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+				Order("created_at").Find(&attachments).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, attachments)
+		})
+
+		// GET /api/attachment/:id/file - Download the file
+		attachment.GET("/:id/file", func(c *gin.Context) {
+			var attachment models.Attachment
+			if err := database.DB.First(&attachment, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+				return
+			}
+
+			if !requireBabyAccess(c, attachment.BabyID) {
+				return
+			}
+
+			blob, err := storage.Store.Get(attachment.StorageKey)
+			if errors.Is(err, storage.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			defer blob.Close()
+
+			contentType, disposition := servedImageType(attachment.ContentType)
+			c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+			c.Header("X-Content-Type-Options", "nosniff")
+			c.Header("Cache-Control", "private, max-age=86400")
+			c.DataFromReader(http.StatusOK, attachment.Size, contentType, blob, nil)
+		})
+
+		attachment.DELETE("/:id", func(c *gin.Context) {
+			var attachment models.Attachment
+			if err := database.DB.First(&attachment, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+				return
+			}
+
+			if !requireBabyAccess(c, attachment.BabyID) {
+				return
+			}
+
+			if err := database.DB.Delete(&models.Attachment{}, "id = ?", attachment.ID).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			removeBlobs([]models.Attachment{attachment})
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+	}
+}
+
+// allowedImageTypes are the image formats accepted for uploads. SVG is left
+// out since it can carry scripts.
+var allowedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// sniffImage detects the type of an uploaded file from its content, ignoring
+// the type the client sent, and rewinds the file. It returns an empty type
+// when the file isn't one of allowedImageTypes.
+func sniffImage(file multipart.File) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	contentType := http.DetectContentType(head[:n])
+	if !allowedImageTypes[contentType] {
+		return "", nil
+	}
+	return contentType, nil
+}
+
+// servedImageType returns the type and disposition to serve a stored image
+// with. Files stored before their type was sniffed are only offered as a
+// download unless they have an allowed type.
+func servedImageType(contentType string) (string, string) {
+	if !allowedImageTypes[contentType] {
+		return "application/octet-stream", "attachment"
+	}
+	return contentType, "inline"
+}
+
+// ownerBabyID returns the baby a record belongs to, or sql.ErrNoRows when
+// the record doesn't exist or is in the trash.
+func ownerBabyID(ownerType, ownerID string) (string, error) {
+	query := database.DB.Table(ownerType).Select("baby_id").Where("id = ?", ownerID)
+	if _, ok := trashModels[ownerType]; ok {
+		query = query.Where("deleted_at IS NULL")
+	}
+
+	var babyID string
+	err := query.Row().Scan(&babyID)
+	return babyID, err
+}
+
+// deleteWithAttachments runs deleteRecord in a transaction together with the
+// deletion of the record's attachments. The files are only removed once the
+// transaction has committed.
+func deleteWithAttachments(ownerType, ownerID string, deleteRecord func(tx *gorm.DB) error) error {
+	var attachments []models.Attachment
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if attachments, err = deleteAttachmentRows(tx, ownerType, ownerID); err != nil {
+			return err
+		}
+		return deleteRecord(tx)
+	}); err != nil {
+		return err
+	}
+
+	removeBlobs(attachments)
+	return nil
+}
+
+// deleteAttachmentRows deletes the attachments of a record and returns them,
+// so that their files can be removed once the transaction has committed.
+func deleteAttachmentRows(tx *gorm.DB, ownerType, ownerID string) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	if err := tx.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).Delete(&models.Attachment{}).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// removeBlobs deletes the files of attachments whose rows are gone. A file
+// that can't be deleted is only logged, it is unreachable either way.
+func removeBlobs(attachments []models.Attachment) {
+	keys := make([]string, len(attachments))
+	for i, attachment := range attachments {
+		keys[i] = attachment.StorageKey
+	}
+	removeBlobKeys(keys)
+}
+
+// removeBlobKeys deletes the files stored under keys, like removeBlobs.
+func removeBlobKeys(keys []string) {
+	for _, key := range keys {
+		if err := storage.Store.Delete(key); err != nil {
+			log.Printf("Failed to delete attachment file %s: %v", key, err)
+		}
+	}
+}
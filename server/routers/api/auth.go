@@ -3,6 +3,12 @@ package api
 import (
 	"baby-tracker/database"
 	"baby-tracker/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"time"
@@ -11,27 +17,134 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	passwordResetTTL       = 24 * time.Hour
+	minPasswordLength      = 8
 )
 
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"deviceName"`
 }
 
 type RegisterRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"deviceName"`
 }
 
-func generateToken(userID string) (string, error) {
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// tokenTTL reads a duration such as "15m" from the environment, falling back
+// to def when it is unset or invalid.
+func tokenTTL(env string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(env)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+func generateToken(userID, sessionID string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"sub": userID,
-		"exp": time.Now().Add(time.Hour * 24 * 7).Unix(), // Token expires in 7 days
+		"sid": sessionID,
+		"exp": time.Now().Add(tokenTTL("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)).Unix(),
 	})
 
 	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
 }
 
+// generateRefreshToken returns a random opaque refresh token. Only its hash is
+// stored, so a database leak doesn't hand out working tokens.
+func generateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// startSession creates a session for the user and writes the login response
+// with a fresh access and refresh token pair.
+func startSession(c *gin.Context, user models.User, deviceName string) {
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	now := time.Now().UTC()
+	session := models.Session{
+		ID:               uuid.NewString(),
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		DeviceName:       deviceName,
+		UserAgent:        c.Request.UserAgent(),
+		IP:               c.ClientIP(),
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(tokenTTL("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	token, err := generateToken(user.ID, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":        token,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(tokenTTL("ACCESS_TOKEN_TTL", defaultAccessTokenTTL).Seconds()),
+		"user": gin.H{
+			"id":                 user.ID,
+			"username":           user.Username,
+			"mustChangePassword": user.MustChangePassword,
+		},
+	})
+}
+
+// revokeSessions revokes every active session of a user except keepSessionID,
+// which may be empty.
+func revokeSessions(tx *gorm.DB, userID, keepSessionID string) error {
+	return tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL AND id <> ?", userID, keepSessionID).
+		Update("revoked_at", time.Now().UTC()).Error
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("Password must be at least %d characters", minPasswordLength)
+	}
+	return nil
+}
+
 func Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -66,20 +179,7 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := generateToken(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-		},
-	})
+	startSession(c, user, req.DeviceName)
 }
 
 func Login(c *gin.Context) {
@@ -103,18 +203,200 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := generateToken(user.ID)
+	startSession(c, user, req.DeviceName)
+}
+
+// Refresh exchanges a refresh token for a new access token. The refresh token
+// is rotated on every use; presenting an already rotated token means it was
+// copied, so the whole session is revoked.
+func Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokenHash := hashToken(req.RefreshToken)
+
+	var session models.Session
+	if err := database.DB.First(&session, "refresh_token_hash = ?", tokenHash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			var reused models.Session
+			if database.DB.First(&reused, "previous_token_hash = ?", tokenHash).Error == nil {
+				database.DB.Model(&reused).Update("revoked_at", time.Now().UTC())
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now().UTC()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired"})
+		return
+	}
+
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	// Only rotate if nobody else rotated the token in the meantime
+	result := database.DB.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, tokenHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  hashToken(refreshToken),
+			"previous_token_hash": tokenHash,
+			"last_used_at":        now,
+			"ip":                  c.ClientIP(),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	token, err := generateToken(session.UserID, session.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-		},
+		"token":        token,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(tokenTTL("ACCESS_TOKEN_TTL", defaultAccessTokenTTL).Seconds()),
+	})
+}
+
+// Logout revokes the session of the access token used for the request.
+func Logout(c *gin.Context) {
+	sessionID := c.GetString("sessionID")
+
+	if err := database.DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now().UTC()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ChangePassword sets a new password for the signed-in user. It is the only
+// API call allowed while a password change is required. Other devices are
+// signed out.
+func ChangePassword(c *gin.Context) {
+	userInterface, _ := c.Get("user")
+	user := userInterface.(models.User)
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	if req.NewPassword == req.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must be different from the current one"})
+		return
+	}
+
+	if err := validatePassword(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"password":             string(hashedPassword),
+			"must_change_password": false,
+		}).Error; err != nil {
+			return err
+		}
+		return revokeSessions(tx, user.ID, c.GetString("sessionID"))
 	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ResetPassword sets a new password using a reset token issued by an admin.
+// All of the user's sessions are revoked.
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validatePassword(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	errInvalidResetToken := errors.New("Invalid or expired reset token")
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Mark the token used first so it can't be redeemed twice
+		now := time.Now().UTC()
+		var resetToken models.PasswordResetToken
+		if err := tx.First(&resetToken, "token_hash = ?", hashToken(req.Token)).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidResetToken
+			}
+			return err
+		}
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", resetToken.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidResetToken
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", resetToken.UserID).Updates(map[string]interface{}{
+			"password":             string(hashedPassword),
+			"must_change_password": false,
+		}).Error; err != nil {
+			return err
+		}
+		return revokeSessions(tx, resetToken.UserID, "")
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
 }
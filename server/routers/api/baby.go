@@ -3,10 +3,19 @@ package api
 import (
 	"baby-tracker/database"
 	"baby-tracker/models"
+	"baby-tracker/storage"
+	"baby-tracker/vaccines"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func SetupBabyRoutes(api *gin.RouterGroup) {
@@ -16,28 +25,27 @@ func SetupBabyRoutes(api *gin.RouterGroup) {
 		baby.GET("/:id", func(c *gin.Context) {
 			id := c.Param("id")
 			var baby models.Baby
-			if err := database.DB.Preload("Parents").First(&baby, "id = ?", id).Error; err != nil {
+			// The immunization record is part of the profile
+			if err := database.DB.Preload("Parents").Preload("Vaccinations", func(db *gorm.DB) *gorm.DB {
+				return db.Order("date")
+			}).First(&baby, "id = ?", id).Error; err != nil {
 				c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
 				return
 			}
 
 			// Check if the current user has access to this baby
-			userInterface, _ := c.Get("user")
-			user := userInterface.(models.User)
-			hasAccess := false
-			for _, parent := range baby.Parents {
-				if parent.ID == user.ID {
-					hasAccess = true
-					break
-				}
+			if !requireBabyRole(c, baby.ID, models.RoleViewer) {
+				return
 			}
 
-			if !hasAccess {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this baby"})
+			userInterface, _ := c.Get("user")
+			role, err := babyRole(userInterface.(models.User).ID, baby.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 
-			c.JSON(http.StatusOK, baby)
+			c.JSON(http.StatusOK, withRole(baby, role))
 		})
 
 		baby.GET("", func(c *gin.Context) {
@@ -50,7 +58,27 @@ func SetupBabyRoutes(api *gin.RouterGroup) {
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 				return
 			}
-			c.JSON(http.StatusOK, user.Babies)
+
+			// Tell the client what the user may do with each baby
+			var memberships []models.UserBaby
+			if err := database.DB.Where("user_id = ?", user.ID).Find(&memberships).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			roles := make(map[string]string, len(memberships))
+			for _, membership := range memberships {
+				roles[membership.BabyID] = membership.Role
+			}
+			// Archived babies are only listed on request
+			includeArchived := c.Query("includeArchived") == "true"
+			babies := []models.Baby{}
+			for _, baby := range user.Babies {
+				if baby.Archived && !includeArchived {
+					continue
+				}
+				babies = append(babies, withRole(baby, roles[baby.ID]))
+			}
+			c.JSON(http.StatusOK, babies)
 		})
 
 		baby.POST("", func(c *gin.Context) {
@@ -58,28 +86,75 @@ func SetupBabyRoutes(api *gin.RouterGroup) {
 			userInterface, _ := c.Get("user")
 			user := userInterface.(models.User)
 
-			var baby models.Baby
-			if err := c.ShouldBindJSON(&baby); err != nil {
+			var babyInput struct {
+				Name               string     `json:"name"`
+				Timezone           string     `json:"timezone"`
+				DayStartHour       *int       `json:"dayStartHour"`
+				NightStartHour     *int       `json:"nightStartHour"`
+				NightEndHour       *int       `json:"nightEndHour"`
+				BirthDate          *time.Time `json:"birthDate"`
+				Sex                string     `json:"sex"`
+				VaccineSchedule    string     `json:"vaccineSchedule"`
+				BirthWeightKg      *float64   `json:"birthWeightKg"`
+				BirthLengthCm      *float64   `json:"birthLengthCm"`
+				GestationalAgeDays *int       `json:"gestationalAgeDays"`
+			}
+			if err := c.ShouldBindJSON(&babyInput); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
 
-			if baby.Name == "" {
+			if babyInput.Name == "" {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Name not provided"})
 				return
 			}
 
-			baby.ID = uuid.NewString()
-			baby.Parents = []models.User{user}
+			baby := models.Baby{
+				ID:                 uuid.NewString(),
+				Name:               babyInput.Name,
+				Timezone:           babyInput.Timezone,
+				DayStartHour:       models.DefaultDayStartHour,
+				NightStartHour:     models.DefaultNightStartHour,
+				NightEndHour:       models.DefaultNightEndHour,
+				BirthDate:          babyInput.BirthDate,
+				Sex:                babyInput.Sex,
+				VaccineSchedule:    babyInput.VaccineSchedule,
+				BirthWeightKg:      babyInput.BirthWeightKg,
+				BirthLengthCm:      babyInput.BirthLengthCm,
+				GestationalAgeDays: babyInput.GestationalAgeDays,
+				Parents:            []models.User{user},
+			}
+			if baby.Timezone == "" {
+				baby.Timezone = models.DefaultTimezone
+			}
+			if baby.VaccineSchedule == "" {
+				baby.VaccineSchedule = models.DefaultVaccineSchedule
+			}
+			if babyInput.DayStartHour != nil {
+				baby.DayStartHour = *babyInput.DayStartHour
+			}
+			if babyInput.NightStartHour != nil {
+				baby.NightStartHour = *babyInput.NightStartHour
+			}
+			if babyInput.NightEndHour != nil {
+				baby.NightEndHour = *babyInput.NightEndHour
+			}
+			if err := validateBaby(baby); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// The join row is created with the default owner role
 			if err := database.DB.Create(&baby).Error; err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 
+			baby.Role = models.RoleOwner
 			c.JSON(http.StatusOK, baby)
 		})
 
-		baby.PUT("/:id", checkBabyAccess(), func(c *gin.Context) {
+		baby.PUT("/:id", func(c *gin.Context) {
 			id := c.Param("id")
 			var baby models.Baby
 			if err := database.DB.First(&baby, "id = ?", id).Error; err != nil {
@@ -87,12 +162,30 @@ func SetupBabyRoutes(api *gin.RouterGroup) {
 				return
 			}
 
+			if !requireBabyRole(c, baby.ID, models.RoleOwner) {
+				return
+			}
+
+			shareToken := baby.ShareToken
+			avatarUpdatedAt := baby.AvatarUpdatedAt
 			if err := c.ShouldBindJSON(&baby); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
+			// Identity, sharing, membership and the avatar have their own endpoints
+			baby.ID = id
+			baby.ShareToken = shareToken
+			baby.AvatarUpdatedAt = avatarUpdatedAt
+			// and so do the baby's records, which are never saved from here
+			baby.Parents, baby.Vaccinations, baby.Measurements = nil, nil, nil
+			baby.Sleeps, baby.Diapers, baby.Nursings = nil, nil, nil
+
+			if err := validateBaby(baby); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 
-			if err := database.DB.Save(&baby).Error; err != nil {
+			if err := database.DB.Omit(clause.Associations).Save(&baby).Error; err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
@@ -100,60 +193,243 @@ func SetupBabyRoutes(api *gin.RouterGroup) {
 			c.JSON(http.StatusOK, baby)
 		})
 
-		baby.POST("/:id/parent", func(c *gin.Context) {
+		// PUT /api/baby/:id/avatar - Upload the avatar as the file field of a
+		// multipart form
+		baby.PUT("/:id/avatar", func(c *gin.Context) {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBytes())
+
+			baby, ok := loadBaby(c, c.Param("id"))
+			if !ok {
+				return
+			}
+
+			if !requireBabyRole(c, baby.ID, models.RoleOwner) {
+				return
+			}
+
+			fileHeader, err := c.FormFile("file")
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "File not provided or too large"})
+				return
+			}
+
+			file, err := fileHeader.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			defer file.Close()
+
+			contentType, err := sniffImage(file)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if contentType == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Avatar must be a PNG, JPEG, GIF or WebP image"})
+				return
+			}
+
+			// A new key per upload, so a failed update keeps the old avatar
+			previousKey := baby.AvatarKey
+			key := baby.ID + "/avatar-" + uuid.NewString()
+			if _, err := storage.Store.Put(key, file); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+				return
+			}
+
+			now := time.Now().UTC()
+			if err := database.DB.Model(&baby).Updates(map[string]interface{}{
+				"avatar_key":          key,
+				"avatar_content_type": contentType,
+				"avatar_updated_at":   now,
+			}).Error; err != nil {
+				storage.Store.Delete(key)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if previousKey != "" {
+				storage.Store.Delete(previousKey)
+			}
+
+			c.JSON(http.StatusOK, gin.H{"avatarUpdatedAt": now})
+		})
+
+		baby.GET("/:id/avatar", func(c *gin.Context) {
+			baby, ok := loadBaby(c, c.Param("id"))
+			if !ok {
+				return
+			}
+
+			if !requireBabyRole(c, baby.ID, models.RoleViewer) {
+				return
+			}
+
+			if baby.AvatarKey == "" {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Baby has no avatar"})
+				return
+			}
+
+			blob, err := storage.Store.Get(baby.AvatarKey)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Avatar not found"})
+				return
+			}
+			defer blob.Close()
+
+			contentType, disposition := servedImageType(baby.AvatarContentType)
+			c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": "avatar"}))
+			c.Header("X-Content-Type-Options", "nosniff")
+			c.Header("Cache-Control", "private, max-age=86400")
+			c.DataFromReader(http.StatusOK, -1, contentType, blob, nil)
+		})
+
+		baby.DELETE("/:id/avatar", func(c *gin.Context) {
+			baby, ok := loadBaby(c, c.Param("id"))
+			if !ok {
+				return
+			}
+
+			if !requireBabyRole(c, baby.ID, models.RoleOwner) {
+				return
+			}
+
+			if err := database.DB.Model(&baby).Updates(map[string]interface{}{
+				"avatar_key":          "",
+				"avatar_content_type": "",
+				"avatar_updated_at":   nil,
+			}).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if baby.AvatarKey != "" {
+				storage.Store.Delete(baby.AvatarKey)
+			}
+
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+
+		baby.GET("/:id/members", func(c *gin.Context) {
 			id := c.Param("id")
+			if !requireBabyRole(c, id, models.RoleViewer) {
+				return
+			}
 
-			// Get user from context
-			userInterface, _ := c.Get("user")
-			user := userInterface.(models.User)
+			var members []struct {
+				UserID    string    `json:"userId"`
+				Username  string    `json:"username"`
+				Role      string    `json:"role"`
+				CreatedAt time.Time `json:"createdAt"`
+			}
+			if err := database.DB.Table("user_babies").
+				Select("user_babies.user_id, users.username, user_babies.role, user_babies.created_at").
+				Joins("JOIN users ON users.id = user_babies.user_id").
+				Where("user_babies.baby_id = ?", id).
+				Order("user_babies.created_at").
+				Scan(&members).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
 
-			var baby models.Baby
-			if err := database.DB.Preload("Parents").First(&baby, "id = ?", id).Error; err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
+			c.JSON(http.StatusOK, members)
+		})
+
+		baby.PUT("/:id/members/:userId", func(c *gin.Context) {
+			id := c.Param("id")
+			if !requireBabyRole(c, id, models.RoleOwner) {
+				return
+			}
+
+			var roleInput struct {
+				Role string `json:"role"`
+			}
+			if err := c.ShouldBindJSON(&roleInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if _, ok := roleRanks[roleInput.Role]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be owner, caregiver or viewer"})
+				return
+			}
+
+			var membership models.UserBaby
+			if err := database.DB.First(&membership, "user_id = ? AND baby_id = ?", c.Param("userId"), id).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
 				return
 			}
 
-			// Check if parent is already added
-			for _, existingParent := range baby.Parents {
-				if existingParent.ID == user.ID {
-					c.JSON(http.StatusBadRequest, gin.H{"error": "Parent already added to this baby"})
+			if membership.Role == models.RoleOwner && roleInput.Role != models.RoleOwner {
+				if ok, err := hasOtherOwner(id, membership.UserID); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				} else if !ok {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "A baby must keep at least one owner"})
 					return
 				}
 			}
 
-			baby.Parents = append(baby.Parents, user)
-			if err := database.DB.Save(&baby).Error; err != nil {
+			if err := database.DB.Model(&models.UserBaby{}).
+				Where("user_id = ? AND baby_id = ?", membership.UserID, id).
+				Update("role", roleInput.Role).Error; err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 
-			c.JSON(http.StatusOK, baby)
+			membership.Role = roleInput.Role
+			c.JSON(http.StatusOK, membership)
 		})
 
-		baby.POST("/:id/share", func(c *gin.Context) {
+		baby.DELETE("/:id/members/:userId", func(c *gin.Context) {
 			id := c.Param("id")
+			memberID := c.Param("userId")
 
-			// Get user from context
+			// Anyone can leave a baby; removing someone else needs an owner
 			userInterface, _ := c.Get("user")
 			user := userInterface.(models.User)
+			minRole := models.RoleOwner
+			if memberID == user.ID {
+				minRole = models.RoleViewer
+			}
+			if !requireBabyRole(c, id, minRole) {
+				return
+			}
 
-			var baby models.Baby
-			if err := database.DB.Preload("Parents").First(&baby, "id = ?", id).Error; err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
+			var membership models.UserBaby
+			if err := database.DB.First(&membership, "user_id = ? AND baby_id = ?", memberID, id).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
 				return
 			}
 
-			// Check if user has access to this baby
-			hasAccess := false
-			for _, parent := range baby.Parents {
-				if parent.ID == user.ID {
-					hasAccess = true
-					break
+			if membership.Role == models.RoleOwner {
+				if ok, err := hasOtherOwner(id, memberID); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				} else if !ok {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "A baby must keep at least one owner"})
+					return
 				}
 			}
 
-			if !hasAccess {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this baby"})
+			if err := database.DB.Where("user_id = ? AND baby_id = ?", memberID, id).Delete(&models.UserBaby{}).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+
+		baby.POST("/:id/share", func(c *gin.Context) {
+			id := c.Param("id")
+
+			var baby models.Baby
+			if err := database.DB.Preload("Parents").First(&baby, "id = ?", id).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
+				return
+			}
+
+			// Only owners can manage sharing
+			if !requireBabyRole(c, baby.ID, models.RoleOwner) {
 				return
 			}
 
@@ -190,27 +466,14 @@ func SetupBabyRoutes(api *gin.RouterGroup) {
 		baby.DELETE("/:id/share", func(c *gin.Context) {
 			id := c.Param("id")
 
-			// Get user from context
-			userInterface, _ := c.Get("user")
-			user := userInterface.(models.User)
-
 			var baby models.Baby
 			if err := database.DB.Preload("Parents").First(&baby, "id = ?", id).Error; err != nil {
 				c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
 				return
 			}
 
-			// Check if user has access to this baby
-			hasAccess := false
-			for _, parent := range baby.Parents {
-				if parent.ID == user.ID {
-					hasAccess = true
-					break
-				}
-			}
-
-			if !hasAccess {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this baby"})
+			// Only owners can manage sharing
+			if !requireBabyRole(c, baby.ID, models.RoleOwner) {
 				return
 			}
 
@@ -224,3 +487,110 @@ func SetupBabyRoutes(api *gin.RouterGroup) {
 		})
 	}
 }
+
+// validateBaby checks the settings and profile fields of a baby.
+func validateBaby(baby models.Baby) error {
+	if _, err := time.LoadLocation(baby.Timezone); err != nil || baby.Timezone == "" {
+		return fmt.Errorf("invalid timezone %q", baby.Timezone)
+	}
+	if baby.DayStartHour < 0 || baby.DayStartHour > 23 {
+		return errors.New("dayStartHour must be between 0 and 23")
+	}
+	if baby.NightStartHour < 0 || baby.NightStartHour > 23 || baby.NightEndHour < 0 || baby.NightEndHour > 23 {
+		return errors.New("nightStartHour and nightEndHour must be between 0 and 23")
+	}
+	if baby.NightStartHour == baby.NightEndHour {
+		return errors.New("nightStartHour and nightEndHour must differ")
+	}
+	if baby.Sex != "" && baby.Sex != "male" && baby.Sex != "female" {
+		return errors.New("sex must be \"male\" or \"female\"")
+	}
+	if baby.BirthDate != nil && baby.BirthDate.After(time.Now()) {
+		return errors.New("birthDate cannot be in the future")
+	}
+	if baby.BirthWeightKg != nil && (*baby.BirthWeightKg < 0.2 || *baby.BirthWeightKg > 7) {
+		return errors.New("birthWeightKg must be between 0.2 and 7")
+	}
+	if baby.BirthLengthCm != nil && (*baby.BirthLengthCm < 20 || *baby.BirthLengthCm > 65) {
+		return errors.New("birthLengthCm must be between 20 and 65")
+	}
+	if baby.GestationalAgeDays != nil && (*baby.GestationalAgeDays < 22*7 || *baby.GestationalAgeDays > 44*7) {
+		return errors.New("gestationalAgeDays must be between 154 (22 weeks) and 308 (44 weeks)")
+	}
+	if _, ok := vaccines.Get(baby.VaccineSchedule); !ok {
+		return fmt.Errorf("unknown vaccineSchedule %q, available: %s", baby.VaccineSchedule, strings.Join(vaccines.Countries(), ", "))
+	}
+	return nil
+}
+
+// hasOtherOwner reports whether the baby has an owner other than userID.
+func hasOtherOwner(babyID, userID string) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.UserBaby{}).
+		Where("baby_id = ? AND role = ? AND user_id <> ?", babyID, models.RoleOwner, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// babyDataModels lists the tables holding a baby's history, deleted along
+// with the baby.
+var babyDataModels = []interface{}{
+	&models.Sleep{},
+	&models.Diaper{},
+	&models.Nursing{},
+	&models.Measurement{},
+	&models.MilkStash{},
+	&models.MilkConsumption{},
+	&models.Medication{},
+	&models.MedicationDose{},
+	&models.Temperature{},
+	&models.Symptom{},
+	&models.IllnessEpisode{},
+	&models.Vaccination{},
+	&models.FoodIntroduction{},
+	&models.Milestone{},
+	&models.Attachment{},
+	&models.EventType{},
+	&models.CustomEvent{},
+	&models.Tombstone{},
+	&models.Invitation{},
+	&models.UserBaby{},
+}
+
+// withRole fills in the requesting user's role for the baby. The share token
+// is only returned to owners, as only they can manage sharing.
+func withRole(baby models.Baby, role string) models.Baby {
+	baby.Role = role
+	if role != models.RoleOwner {
+		baby.ShareToken = ""
+	}
+	return baby
+}
+
+// deleteBabyData deletes a baby and everything recorded for it. It returns
+// the storage keys of the uploaded files, avatar included, for the caller to
+// remove once the transaction has committed.
+func deleteBabyData(tx *gorm.DB, babyID string) ([]string, error) {
+	var keys []string
+	if err := tx.Model(&models.Attachment{}).Where("baby_id = ?", babyID).Pluck("storage_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	var baby models.Baby
+	if err := tx.First(&baby, "id = ?", babyID).Error; err != nil {
+		return nil, err
+	}
+	if baby.AvatarKey != "" {
+		keys = append(keys, baby.AvatarKey)
+	}
+
+	// Including events in the trash
+	for _, model := range babyDataModels {
+		if err := tx.Unscoped().Where("baby_id = ?", babyID).Delete(model).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Delete(&models.Baby{}, "id = ?", babyID).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
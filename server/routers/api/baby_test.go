@@ -0,0 +1,26 @@
+package api
+
+import (
+	"baby-tracker/models"
+	"testing"
+)
+
+func TestWithRoleHidesShareToken(t *testing.T) {
+	tests := []struct {
+		role  string
+		token string
+	}{
+		{models.RoleOwner, "secret"},
+		{models.RoleCaregiver, ""},
+		{models.RoleViewer, ""},
+	}
+	for _, tt := range tests {
+		baby := withRole(models.Baby{ID: "baby", ShareToken: "secret"}, tt.role)
+		if baby.Role != tt.role {
+			t.Errorf("%s: Role = %q", tt.role, baby.Role)
+		}
+		if baby.ShareToken != tt.token {
+			t.Errorf("%s: ShareToken = %q, want %q", tt.role, baby.ShareToken, tt.token)
+		}
+	}
+}
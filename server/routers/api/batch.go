@@ -0,0 +1,277 @@
+package api
+
+import (
+	"baby-tracker/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxBatchOperations bounds the size of a batch upload.
+const maxBatchOperations = 500
+
+var (
+	errBatchInvalid         = errors.New("invalid operation")
+	errBatchNotFound        = errors.New("record not found")
+	errBatchVersionRequired = errors.New("version is required to update a record")
+)
+
+// batchOperation is a change a client queued while offline. Data holds the
+// record as returned by the API, like the body of a PUT.
+type batchOperation struct {
+	Op   string `json:"op"`   // create, update or delete
+	Type string `json:"type"` // sleep, diaper or nursing
+	ID   string `json:"id"`
+	// IdempotencyKey makes a create without an ID safe to retry, like the
+	// Idempotency-Key header of a single create. Keys are scoped by type, so
+	// the operation can move within the batch between attempts.
+	IdempotencyKey string          `json:"idempotencyKey"`
+	Data           json.RawMessage `json:"data"`
+	// Version is the version an update was based on, like If-Match
+	Version int `json:"version"`
+}
+
+// batchStatus returns the HTTP status reported for a failed operation.
+func batchStatus(err error) int {
+	switch {
+	case errors.Is(err, errBatchInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, errBatchNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errIDTaken):
+		return http.StatusConflict
+	case errors.Is(err, errRecordDeleted):
+		return http.StatusGone
+	case errors.Is(err, errBatchVersionRequired):
+		return http.StatusPreconditionRequired
+	case errors.Is(err, errVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		// A timer is already running for the baby
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// applyBatchOperation applies an operation to a record of the baby and
+// returns the saved record. An update based on an outdated version fails with
+// errVersionConflict and returns the current record.
+//
+// Operations are safe to replay: creating a record that exists returns it
+// and deleting one that is gone succeeds.
+func applyBatchOperation(tx *gorm.DB, op batchOperation, babyID string) (gin.H, error) {
+	switch op.Op {
+	case "create", "update", "delete":
+	default:
+		return nil, fmt.Errorf("%w: op must be create, update or delete", errBatchInvalid)
+	}
+
+	if op.Op == "update" && op.Version == 0 {
+		return nil, errBatchVersionRequired
+	}
+	if op.Op == "create" {
+		// New records start at version 1
+		op.Version = 0
+	}
+
+	switch op.Type {
+	case "sleep":
+		return applySleepOperation(tx, op, babyID)
+	case "diaper":
+		return applyDiaperOperation(tx, op, babyID)
+	case "nursing":
+		return applyNursingOperation(tx, op, babyID)
+	default:
+		return nil, fmt.Errorf("%w: type must be sleep, diaper or nursing", errBatchInvalid)
+	}
+}
+
+// loadBatchRecord loads the record targeted by an operation. done is true when
+// there is nothing left to do because the operation was already applied.
+func loadBatchRecord(tx *gorm.DB, record interface{}, op batchOperation, babyID string) (done bool, err error) {
+	found, err := findCreated(tx, record, op.ID, babyID)
+	if errors.Is(err, errRecordDeleted) && op.Op == "delete" {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch op.Op {
+	case "create":
+		return found, nil
+	case "update":
+		if !found {
+			return false, errBatchNotFound
+		}
+		return false, nil
+	default:
+		return !found, nil
+	}
+}
+
+// decodeBatchRecord decodes the data of a create or update into record.
+func decodeBatchRecord(op batchOperation, record interface{}) error {
+	if err := json.Unmarshal(op.Data, record); err != nil {
+		return fmt.Errorf("%w: %v", errBatchInvalid, err)
+	}
+	return nil
+}
+
+// saveBatchRecord inserts or updates the record of an operation, whose
+// version has already been set.
+func saveBatchRecord(tx *gorm.DB, op batchOperation, record interface{}) error {
+	if op.Op == "create" {
+		return tx.Create(record).Error
+	}
+	return saveVersion(tx, record, op.Version)
+}
+
+func applySleepOperation(tx *gorm.DB, op batchOperation, babyID string) (gin.H, error) {
+	var sleep models.Sleep
+	done, err := loadBatchRecord(tx, &sleep, op, babyID)
+	if err != nil {
+		return nil, err
+	}
+	if done {
+		if op.Op == "create" {
+			return formatSleep(sleep), nil
+		}
+		return nil, nil
+	}
+
+	if op.Op == "delete" {
+		return nil, deleteRecords(tx, &models.Sleep{}, "id = ?", sleep.ID)
+	}
+
+	if op.Op == "update" && op.Version != sleep.Version {
+		return formatSleep(sleep), errVersionConflict
+	}
+
+	var edit sleepEdit
+	if err := decodeBatchRecord(op, &edit); err != nil {
+		return nil, err
+	}
+	sleep.ID, sleep.BabyID, sleep.Version = op.ID, babyID, op.Version+1
+	// As with PUT, only the timer endpoints start and stop an existing sleep
+	if op.Op == "create" {
+		sleep.InProgress = edit.InProgress
+	}
+	if err := edit.apply(&sleep); err != nil {
+		return nil, fmt.Errorf("%w: %v", errBatchInvalid, err)
+	}
+
+	if err := saveBatchRecord(tx, op, &sleep); err != nil {
+		return nil, err
+	}
+	return formatSleep(sleep), nil
+}
+
+func applyDiaperOperation(tx *gorm.DB, op batchOperation, babyID string) (gin.H, error) {
+	var diaper models.Diaper
+	done, err := loadBatchRecord(tx, &diaper, op, babyID)
+	if err != nil {
+		return nil, err
+	}
+	if done {
+		if op.Op == "create" {
+			return formatDiaper(diaper), nil
+		}
+		return nil, nil
+	}
+
+	if op.Op == "delete" {
+		return nil, deleteRecords(tx, &models.Diaper{}, "id = ?", diaper.ID)
+	}
+
+	if op.Op == "update" && op.Version != diaper.Version {
+		return formatDiaper(diaper), errVersionConflict
+	}
+
+	var edit diaperEdit
+	if err := decodeBatchRecord(op, &edit); err != nil {
+		return nil, err
+	}
+	diaper.ID, diaper.BabyID, diaper.Version = op.ID, babyID, op.Version+1
+	if err := edit.apply(&diaper); err != nil {
+		return nil, fmt.Errorf("%w: %v", errBatchInvalid, err)
+	}
+
+	if err := saveBatchRecord(tx, op, &diaper); err != nil {
+		return nil, err
+	}
+	return formatDiaper(diaper), nil
+}
+
+func applyNursingOperation(tx *gorm.DB, op batchOperation, babyID string) (gin.H, error) {
+	var nursing models.Nursing
+	done, err := loadBatchRecord(tx, &nursing, op, babyID)
+	if err != nil {
+		return nil, err
+	}
+	if done {
+		if op.Op == "create" {
+			return formatNursing(nursing), nil
+		}
+		return nil, nil
+	}
+
+	if op.Op == "delete" {
+		// Milk the feed drew from the stash goes back into it
+		if err := releaseNursingMilk(tx, nursing.ID); err != nil {
+			return nil, err
+		}
+		return nil, deleteRecords(tx, &models.Nursing{}, "id = ?", nursing.ID)
+	}
+
+	if op.Op == "update" && op.Version != nursing.Version {
+		return formatNursing(nursing), errVersionConflict
+	}
+
+	var edit nursingEdit
+	if err := decodeBatchRecord(op, &edit); err != nil {
+		return nil, err
+	}
+	previous := nursing
+	nursing.ID, nursing.BabyID, nursing.Version = op.ID, babyID, op.Version+1
+	// As with PUT, only the timer endpoints start and stop an existing session
+	if op.Op == "create" {
+		nursing.InProgress = edit.InProgress
+	}
+	if err := edit.apply(&nursing); err != nil {
+		return nil, fmt.Errorf("%w: %v", errBatchInvalid, err)
+	}
+	if err := validateStorage(nursing.Kind, edit.Storage); err != nil {
+		return nil, fmt.Errorf("%w: %v", errBatchInvalid, err)
+	}
+
+	if err := saveBatchRecord(tx, op, &nursing); err != nil {
+		return nil, err
+	}
+
+	var milk gin.H
+	if op.Op == "create" {
+		if !nursing.InProgress {
+			milk, err = stashNursing(tx, nursing, edit.Storage, edit.FromStash == nil || *edit.FromStash)
+		}
+	} else {
+		milk, err = restashNursing(tx, previous, nursing, edit.Storage, edit.FromStash)
+	}
+	if errors.Is(err, errStashEdit) {
+		return nil, fmt.Errorf("%w: %v", errBatchInvalid, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response := formatNursing(nursing)
+	if milk != nil {
+		response["milk"] = milk
+	}
+	return response, nil
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBatchCreateIgnoresClientUpdatedAt(t *testing.T) {
+	setupTestDB(t)
+	r := newTestRouter()
+
+	user, token := createTestUser(t, false)
+	baby := createTestBaby(t, user.ID)
+	since := time.Now().UTC()
+
+	diaperID := uuid.NewString()
+	batch := map[string]interface{}{
+		"babyId": baby.ID,
+		"operations": []map[string]interface{}{{
+			"op":   "create",
+			"type": "diaper",
+			"id":   diaperID,
+			"data": map[string]interface{}{
+				"type":      "wet",
+				"time":      since.Format(time.RFC3339),
+				"updatedAt": "2000-01-01T00:00:00Z",
+			},
+		}},
+	}
+	var batchResponse struct {
+		Results []struct {
+			Status int `json:"status"`
+		} `json:"results"`
+	}
+	if code := doRequest(t, r, http.MethodPost, "/api/sync/batch", token, nil, batch, &batchResponse); code != http.StatusOK {
+		t.Fatalf("POST /api/sync/batch returned %d", code)
+	}
+	if len(batchResponse.Results) != 1 || batchResponse.Results[0].Status != http.StatusOK {
+		t.Fatalf("create failed: %+v", batchResponse.Results)
+	}
+
+	var syncResponse struct {
+		Changes struct {
+			Diapers []struct {
+				ID string `json:"id"`
+			} `json:"diapers"`
+		} `json:"changes"`
+	}
+	path := "/api/sync?babyId=" + baby.ID + "&since=" + url.QueryEscape(since.Format(time.RFC3339Nano))
+	if code := doRequest(t, r, http.MethodGet, path, token, nil, nil, &syncResponse); code != http.StatusOK {
+		t.Fatalf("GET /api/sync returned %d", code)
+	}
+	for _, diaper := range syncResponse.Changes.Diapers {
+		if diaper.ID == diaperID {
+			return
+		}
+	}
+	t.Errorf("diaper created with an old updatedAt is missing from the sync: %+v", syncResponse.Changes.Diapers)
+}
+
+func TestBatchCreateRetryWithShiftedOperations(t *testing.T) {
+	setupTestDB(t)
+	r := newTestRouter()
+
+	user, token := createTestUser(t, false)
+	baby := createTestBaby(t, user.ID)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	diaper := func(key string) map[string]interface{} {
+		return map[string]interface{}{
+			"op":             "create",
+			"type":           "diaper",
+			"idempotencyKey": key,
+			"data":           map[string]interface{}{"type": "wet", "time": now},
+		}
+	}
+	type batchResponse struct {
+		Results []struct {
+			ID     string `json:"id"`
+			Status int    `json:"status"`
+		} `json:"results"`
+	}
+
+	var first batchResponse
+	if code := doRequest(t, r, http.MethodPost, "/api/sync/batch", token, nil, map[string]interface{}{
+		"babyId":     baby.ID,
+		"operations": []map[string]interface{}{diaper("a")},
+	}, &first); code != http.StatusOK {
+		t.Fatalf("POST /api/sync/batch returned %d", code)
+	}
+	if len(first.Results) != 1 || first.Results[0].Status != http.StatusOK {
+		t.Fatalf("create failed: %+v", first.Results)
+	}
+
+	// The retry has a new operation queued ahead of the first one
+	var retry batchResponse
+	if code := doRequest(t, r, http.MethodPost, "/api/sync/batch", token, nil, map[string]interface{}{
+		"babyId":     baby.ID,
+		"operations": []map[string]interface{}{diaper("b"), diaper("a")},
+	}, &retry); code != http.StatusOK {
+		t.Fatalf("POST /api/sync/batch returned %d", code)
+	}
+	if len(retry.Results) != 2 || retry.Results[1].ID != first.Results[0].ID {
+		t.Fatalf("retried create got %+v, want ID %s", retry.Results, first.Results[0].ID)
+	}
+	if retry.Results[0].ID == first.Results[0].ID {
+		t.Errorf("new create reused ID %s", first.Results[0].ID)
+	}
+
+	var syncResponse struct {
+		Changes struct {
+			Diapers []struct {
+				ID string `json:"id"`
+			} `json:"diapers"`
+		} `json:"changes"`
+	}
+	if code := doRequest(t, r, http.MethodGet, "/api/sync?babyId="+baby.ID, token, nil, nil, &syncResponse); code != http.StatusOK {
+		t.Fatalf("GET /api/sync returned %d", code)
+	}
+	if len(syncResponse.Changes.Diapers) != 2 {
+		t.Errorf("got %d diapers, want 2", len(syncResponse.Changes.Diapers))
+	}
+}
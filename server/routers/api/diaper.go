@@ -3,11 +3,12 @@ package api
 import (
 	"baby-tracker/database"
 	"baby-tracker/models"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 func SetupDiaperRoutes(api *gin.RouterGroup) {
@@ -16,6 +17,7 @@ func SetupDiaperRoutes(api *gin.RouterGroup) {
 	{
 		diaper.POST("", checkBabyAccess(), func(c *gin.Context) {
 			var diaperInput struct {
+				ID     string `json:"id"` // client generated, makes retries safe
 				Type   string `json:"type"`
 				Time   string `json:"time"`
 				BabyID string `json:"babyId"`
@@ -33,8 +35,19 @@ func SetupDiaperRoutes(api *gin.RouterGroup) {
 				return
 			}
 
+			id, err := recordID(c, diaperInput.ID, "diaper")
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			var existing models.Diaper
+			if replayCreate(c, &existing, id, diaperInput.BabyID, func() gin.H { return formatDiaper(existing) }) {
+				return
+			}
+
 			diaper := models.Diaper{
-				ID:     uuid.NewString(),
+				ID:     id,
 				Type:   diaperInput.Type,
 				Time:   diaperTime.UTC(),
 				BabyID: diaperInput.BabyID,
@@ -42,49 +55,20 @@ func SetupDiaperRoutes(api *gin.RouterGroup) {
 			}
 
 			if err := database.DB.Create(&diaper).Error; err != nil {
+				// A concurrent attempt of the same request may have inserted it
+				if replayCreate(c, &existing, id, diaper.BabyID, func() gin.H { return formatDiaper(existing) }) {
+					return
+				}
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 
-			c.JSON(http.StatusOK, gin.H{
-				"id":     diaper.ID,
-				"type":   diaper.Type,
-				"time":   diaper.Time.Format(time.RFC3339),
-				"babyId": diaper.BabyID,
-				"note":   diaper.Note,
-			})
+			c.JSON(http.StatusOK, formatDiaper(diaper))
 		})
 
-		diaper.GET("", func(c *gin.Context) {
-			// Get user from context
-			userInterface, _ := c.Get("user")
-			user := userInterface.(models.User)
-
+		diaper.GET("", checkBabyAccess(), func(c *gin.Context) {
 			// Get babyId from query parameter
 			babyID := c.Query("babyId")
-			if babyID == "" {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Baby ID not provided"})
-				return
-			}
-
-			// Check if user has access to this baby
-			if err := database.DB.Preload("Babies").First(&user, "id = ?", user.ID).Error; err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-				return
-			}
-
-			hasAccess := false
-			for _, baby := range user.Babies {
-				if baby.ID == babyID {
-					hasAccess = true
-					break
-				}
-			}
-
-			if !hasAccess {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this baby"})
-				return
-			}
 
 			var diapers []models.Diaper
 			if err := database.DB.Where("baby_id = ?", babyID).Find(&diapers).Error; err != nil {
@@ -95,39 +79,115 @@ func SetupDiaperRoutes(api *gin.RouterGroup) {
 			// Convert times to RFC3339 format
 			response := make([]gin.H, len(diapers))
 			for i, diaper := range diapers {
-				response[i] = gin.H{
-					"id":     diaper.ID,
-					"type":   diaper.Type,
-					"time":   diaper.Time.Format(time.RFC3339),
-					"babyId": diaper.BabyID,
-					"note":   diaper.Note,
-				}
+				response[i] = formatDiaper(diaper)
 			}
 			c.JSON(http.StatusOK, response)
 		})
 
-		diaper.DELETE("/:id", checkBabyAccess(), func(c *gin.Context) {
+		diaper.DELETE("/:id", checkRecordAccess("diapers", "Diaper"), func(c *gin.Context) {
 			id := c.Param("id")
-			if err := database.DB.Delete(&models.Diaper{}, "id = ?", id).Error; err != nil {
+			var existing models.Diaper
+			if err := database.DB.First(&existing, "id = ?", id).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Diaper not found"})
+				return
+			}
+
+			// The diaper goes to the trash, its attachments stay until it is purged
+			if err := deleteRecords(database.DB, &models.Diaper{}, "id = ?", id); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 			c.JSON(http.StatusOK, gin.H{"success": true})
 		})
 
-		diaper.PUT("/:id", checkBabyAccess(), func(c *gin.Context) {
-			id := c.Param("id")
+		// Restore takes a deleted diaper out of the trash, e.g. to undo a
+		// delete
+		diaper.POST("/:id/restore", checkRecordAccess("diapers", "Diaper"), func(c *gin.Context) {
 			var diaper models.Diaper
-			if err := c.ShouldBindJSON(&diaper); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			if !loadTrashed(c, &diaper, "Diaper") {
+				return
+			}
+
+			if !checkRestorable(c, diaper.DeletedAt) {
 				return
 			}
-			diaper.ID = id
-			if err := database.DB.Save(&diaper).Error; err != nil {
+
+			if err := database.DB.Transaction(func(tx *gorm.DB) error {
+				return restoreRecord(tx, "diapers", &diaper, diaper.ID)
+			}); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
-			c.JSON(http.StatusOK, diaper)
+
+			c.JSON(http.StatusOK, formatDiaper(diaper))
 		})
+
+		diaper.PUT("/:id", checkRecordAccess("diapers", "Diaper"), func(c *gin.Context) {
+			id := c.Param("id")
+			var existing models.Diaper
+			if err := database.DB.First(&existing, "id = ?", id).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Diaper not found"})
+				return
+			}
+
+			version, ok := ifMatchVersion(c)
+			if !ok {
+				return
+			}
+			if version != existing.Version {
+				preconditionFailed(c, existing.Version, formatDiaper(existing))
+				return
+			}
+
+			diaper := existing
+			if !bindUpdate(c, &diaper, &diaperEdit{}) {
+				return
+			}
+			diaper.Version = version + 1
+			err := saveVersion(database.DB, &diaper, version)
+			if errors.Is(err, errVersionConflict) {
+				// Another update got in since the version was checked
+				if err := database.DB.First(&existing, "id = ?", id).Error; err != nil {
+					c.JSON(http.StatusNotFound, gin.H{"error": "Diaper not found"})
+					return
+				}
+				preconditionFailed(c, existing.Version, formatDiaper(existing))
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			setETag(c, diaper.Version)
+			c.JSON(http.StatusOK, formatDiaper(diaper))
+		})
+	}
+}
+
+// diaperEdit is the body of a diaper edit, for PUT and batch operations: the
+// diaper as the API returns it.
+type diaperEdit struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Note string    `json:"note"`
+}
+
+// apply copies the editable fields onto diaper. Identity and version are left
+// alone.
+func (edit diaperEdit) apply(diaper *models.Diaper) error {
+	diaper.Type = edit.Type
+	diaper.Time = edit.Time.UTC()
+	diaper.Note = edit.Note
+	return nil
+}
+
+func formatDiaper(diaper models.Diaper) gin.H {
+	return gin.H{
+		"id":      diaper.ID,
+		"type":    diaper.Type,
+		"time":    diaper.Time.Format(time.RFC3339),
+		"babyId":  diaper.BabyID,
+		"note":    diaper.Note,
+		"version": diaper.Version,
 	}
 }
@@ -0,0 +1,375 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxEventTextLength is the longest value a text field accepts.
+const maxEventTextLength = 2000
+
+func SetupEventRoutes(api *gin.RouterGroup) {
+	event := api.Group("/event")
+	event.Use(AuthMiddleware()) // Add authentication middleware
+	{
+		// POST /api/event/types - Define a custom event type
+		event.POST("/types", checkBabyAccess(), func(c *gin.Context) {
+			var input eventTypeInput
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			eventType := models.EventType{ID: uuid.NewString(), BabyID: input.BabyID}
+			if err := input.apply(&eventType); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if err := database.DB.Create(&eventType).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, eventType)
+		})
+
+		event.GET("/types", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			var eventTypes []models.EventType
+			if err := database.DB.Where("baby_id = ?", babyID).Order("name").Find(&eventTypes).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, eventTypes)
+		})
+
+		// PUT /api/event/types/:id - Update a definition. Events already
+		// recorded are kept as they are and only validated again when edited.
+		event.PUT("/types/:id", checkRecordAccess("event_types", "Event type"), func(c *gin.Context) {
+			var eventType models.EventType
+			if err := database.DB.First(&eventType, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Event type not found"})
+				return
+			}
+
+			if !bindUpdate(c, &eventType, &eventTypeInput{}) {
+				return
+			}
+
+			if err := database.DB.Save(&eventType).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, eventType)
+		})
+
+		// DELETE /api/event/types/:id - Delete a definition and its events
+		event.DELETE("/types/:id", checkRecordAccess("event_types", "Event type"), func(c *gin.Context) {
+			var eventType models.EventType
+			if err := database.DB.First(&eventType, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Event type not found"})
+				return
+			}
+
+			if err := database.DB.Transaction(func(tx *gorm.DB) error {
+				if err := deleteRecords(tx, &models.CustomEvent{}, "event_type_id = ?", eventType.ID); err != nil {
+					return err
+				}
+				return deleteRecords(tx, &models.EventType{}, "id = ?", eventType.ID)
+			}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+
+		event.POST("", checkBabyAccess(), func(c *gin.Context) {
+			var eventInput struct {
+				EventTypeID string             `json:"eventTypeId"`
+				BabyID      string             `json:"babyId"`
+				Time        string             `json:"time"`
+				Values      models.EventValues `json:"values"`
+				Note        string             `json:"note"`
+			}
+			if err := c.ShouldBindJSON(&eventInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			eventTime, err := time.Parse(time.RFC3339, eventInput.Time)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time format"})
+				return
+			}
+
+			var eventType models.EventType
+			if err := database.DB.First(&eventType, "id = ? AND baby_id = ?", eventInput.EventTypeID, eventInput.BabyID).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Event type not found"})
+				return
+			}
+
+			values, err := validateEventValues(eventType.Fields, eventInput.Values)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			event := models.CustomEvent{
+				ID:          uuid.NewString(),
+				EventTypeID: eventType.ID,
+				BabyID:      eventInput.BabyID,
+				Time:        eventTime.UTC(),
+				Values:      values,
+				Note:        eventInput.Note,
+			}
+			if err := database.DB.Create(&event).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, formatCustomEvent(event, eventType))
+		})
+
+		// GET /api/event?babyId=&eventTypeId= - List events, optionally of one type
+		event.GET("", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			query := database.DB.Where("baby_id = ?", babyID)
+			if eventTypeID := c.Query("eventTypeId"); eventTypeID != "" {
+				query = query.Where("event_type_id = ?", eventTypeID)
+			}
+
+			var events []models.CustomEvent
+			if err := query.Order("time").Find(&events).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			response, err := formatCustomEvents(babyID, events)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, response)
+		})
+
+		event.PUT("/:id", checkRecordAccess("custom_events", "Event"), func(c *gin.Context) {
+			var event models.CustomEvent
+			if err := database.DB.First(&event, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+				return
+			}
+
+			var eventInput struct {
+				Time   string             `json:"time"`
+				Values models.EventValues `json:"values"`
+				Note   string             `json:"note"`
+			}
+			if err := c.ShouldBindJSON(&eventInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			eventTime, err := time.Parse(time.RFC3339, eventInput.Time)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time format"})
+				return
+			}
+
+			var eventType models.EventType
+			if err := database.DB.First(&eventType, "id = ?", event.EventTypeID).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Event type not found"})
+				return
+			}
+
+			values, err := validateEventValues(eventType.Fields, eventInput.Values)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			event.Time = eventTime.UTC()
+			event.Values = values
+			event.Note = eventInput.Note
+			if err := database.DB.Save(&event).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, formatCustomEvent(event, eventType))
+		})
+
+		event.DELETE("/:id", checkRecordAccess("custom_events", "Event"), func(c *gin.Context) {
+			var event models.CustomEvent
+			if err := database.DB.First(&event, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+				return
+			}
+
+			if err := deleteRecords(database.DB, &models.CustomEvent{}, "id = ?", event.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+	}
+}
+
+// eventTypeInput is the body of an event type create or update. BabyID is
+// only read on create.
+type eventTypeInput struct {
+	BabyID string             `json:"babyId"`
+	Name   string             `json:"name"`
+	Icon   string             `json:"icon"`
+	Fields models.EventFields `json:"fields"`
+}
+
+func (input eventTypeInput) apply(eventType *models.EventType) error {
+	eventType.Name = input.Name
+	eventType.Icon = input.Icon
+	eventType.Fields = input.Fields
+	if eventType.Fields == nil {
+		eventType.Fields = models.EventFields{}
+	}
+	return validateEventType(*eventType)
+}
+
+// validateEventType checks the name and field definitions of an event type.
+func validateEventType(eventType models.EventType) error {
+	if eventType.Name == "" {
+		return errors.New("name is required")
+	}
+
+	keys := make(map[string]bool, len(eventType.Fields))
+	for _, field := range eventType.Fields {
+		if field.Key == "" {
+			return errors.New("every field needs a key")
+		}
+		if keys[field.Key] {
+			return fmt.Errorf("duplicate field key %q", field.Key)
+		}
+		keys[field.Key] = true
+
+		switch field.Type {
+		case models.FieldDuration, models.FieldNumber, models.FieldText:
+		case models.FieldEnum:
+			if len(field.Options) == 0 {
+				return fmt.Errorf("enum field %q needs options", field.Key)
+			}
+		default:
+			return fmt.Errorf("field %q has invalid type %q, use duration, number, enum or text", field.Key, field.Type)
+		}
+
+		if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+			return fmt.Errorf("field %q has min greater than max", field.Key)
+		}
+	}
+	return nil
+}
+
+// validateEventValues checks event values against the fields of their type
+// and returns them without empty optional values.
+func validateEventValues(fields models.EventFields, values models.EventValues) (models.EventValues, error) {
+	known := make(map[string]bool, len(fields))
+	valid := models.EventValues{}
+	for _, field := range fields {
+		known[field.Key] = true
+
+		value, ok := values[field.Key]
+		if !ok || value == nil || value == "" {
+			if field.Required {
+				return nil, fmt.Errorf("%s is required", field.Key)
+			}
+			continue
+		}
+
+		switch field.Type {
+		case models.FieldDuration, models.FieldNumber:
+			number, ok := value.(float64)
+			if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+				return nil, fmt.Errorf("%s must be a number", field.Key)
+			}
+			if field.Type == models.FieldDuration && number < 0 {
+				return nil, fmt.Errorf("%s cannot be negative", field.Key)
+			}
+			if field.Min != nil && number < *field.Min {
+				return nil, fmt.Errorf("%s must be at least %g", field.Key, *field.Min)
+			}
+			if field.Max != nil && number > *field.Max {
+				return nil, fmt.Errorf("%s must be at most %g", field.Key, *field.Max)
+			}
+		case models.FieldEnum:
+			text, _ := value.(string)
+			allowed := false
+			for _, option := range field.Options {
+				if text == option {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return nil, fmt.Errorf("%s must be one of %v", field.Key, field.Options)
+			}
+		case models.FieldText:
+			text, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be text", field.Key)
+			}
+			if len(text) > maxEventTextLength {
+				return nil, fmt.Errorf("%s is longer than %d characters", field.Key, maxEventTextLength)
+			}
+		}
+		valid[field.Key] = value
+	}
+
+	for key := range values {
+		if !known[key] {
+			return nil, fmt.Errorf("unknown field %q", key)
+		}
+	}
+	return valid, nil
+}
+
+// formatCustomEvent converts an event to its API representation, with the
+// name and icon of its type.
+func formatCustomEvent(event models.CustomEvent, eventType models.EventType) gin.H {
+	return gin.H{
+		"id":          event.ID,
+		"eventTypeId": event.EventTypeID,
+		"typeName":    eventType.Name,
+		"icon":        eventType.Icon,
+		"time":        event.Time.Format(time.RFC3339),
+		"values":      event.Values,
+		"babyId":      event.BabyID,
+		"note":        event.Note,
+	}
+}
+
+// formatCustomEvents formats the events of a baby, looking up their types.
+func formatCustomEvents(babyID string, events []models.CustomEvent) ([]gin.H, error) {
+	var eventTypes []models.EventType
+	if err := database.DB.Where("baby_id = ?", babyID).Find(&eventTypes).Error; err != nil {
+		return nil, err
+	}
+	typesByID := make(map[string]models.EventType, len(eventTypes))
+	for _, eventType := range eventTypes {
+		typesByID[eventType.ID] = eventType
+	}
+
+	response := make([]gin.H, len(events))
+	for i, event := range events {
+		response[i] = formatCustomEvent(event, typesByID[event.EventTypeID])
+	}
+	return response, nil
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// allergenCategories are the common food allergens tracked separately, in the
+// order they are usually introduced.
+var allergenCategories = []string{
+	"egg", "peanut", "milk", "wheat", "soy", "tree_nut", "sesame", "fish", "shellfish",
+}
+
+// reactionRanks orders reaction severities from none to severe.
+var reactionRanks = map[string]int{
+	models.ReactionNone:     0,
+	models.ReactionMild:     1,
+	models.ReactionModerate: 2,
+	models.ReactionSevere:   3,
+}
+
+func SetupFoodRoutes(api *gin.RouterGroup) {
+	food := api.Group("/food")
+	food.Use(AuthMiddleware()) // Add authentication middleware
+	{
+		food.POST("", checkBabyAccess(), func(c *gin.Context) {
+			var input foodInput
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			food := models.FoodIntroduction{ID: uuid.NewString(), BabyID: input.BabyID}
+			if err := input.apply(&food); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if err := database.DB.Create(&food).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, food)
+		})
+
+		food.GET("", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			var foods []models.FoodIntroduction
+			if err := database.DB.Where("baby_id = ?", babyID).Order("time").Find(&foods).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, foods)
+		})
+
+		// GET /api/food/exposures?babyId= - First exposure to each allergen and
+		// foods that were only tried once
+		food.GET("/exposures", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			var foods []models.FoodIntroduction
+			if err := database.DB.Where("baby_id = ?", babyID).Order("time").Find(&foods).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, buildFoodExposures(foods))
+		})
+
+		food.PUT("/:id", checkRecordAccess("food_introductions", "Food"), func(c *gin.Context) {
+			var food models.FoodIntroduction
+			if err := database.DB.First(&food, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+				return
+			}
+
+			if !bindUpdate(c, &food, &foodInput{}) {
+				return
+			}
+
+			if err := database.DB.Save(&food).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, food)
+		})
+
+		food.DELETE("/:id", checkRecordAccess("food_introductions", "Food"), func(c *gin.Context) {
+			var food models.FoodIntroduction
+			if err := database.DB.First(&food, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+				return
+			}
+
+			if err := deleteRecords(database.DB, &models.FoodIntroduction{}, "id = ?", food.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+	}
+}
+
+// foodInput is the body of a food introduction create or update. BabyID is
+// only read on create.
+type foodInput struct {
+	Time             string `json:"time"`
+	Food             string `json:"food"`
+	AllergenCategory string `json:"allergenCategory"`
+	Amount           string `json:"amount"`
+	ReactionSeverity string `json:"reactionSeverity"`
+	Reaction         string `json:"reaction"`
+	BabyID           string `json:"babyId"`
+	Note             string `json:"note"`
+}
+
+func (input foodInput) apply(food *models.FoodIntroduction) error {
+	foodTime, err := time.Parse(time.RFC3339, input.Time)
+	if err != nil {
+		return errors.New("Invalid time format")
+	}
+
+	food.Time = foodTime.UTC()
+	food.Food = input.Food
+	food.AllergenCategory = input.AllergenCategory
+	food.Amount = input.Amount
+	food.ReactionSeverity = input.ReactionSeverity
+	food.Reaction = input.Reaction
+	food.Note = input.Note
+	return normalizeFood(food)
+}
+
+// normalizeFood validates a food introduction, defaulting the reaction
+// severity to none.
+func normalizeFood(food *models.FoodIntroduction) error {
+	food.Food = strings.TrimSpace(food.Food)
+	if food.Food == "" {
+		return errors.New("food is required")
+	}
+
+	if food.AllergenCategory != "" {
+		known := false
+		for _, category := range allergenCategories {
+			if food.AllergenCategory == category {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown allergenCategory %q, use one of %s", food.AllergenCategory, strings.Join(allergenCategories, ", "))
+		}
+	}
+
+	if food.ReactionSeverity == "" {
+		food.ReactionSeverity = models.ReactionNone
+	}
+	if _, ok := reactionRanks[food.ReactionSeverity]; !ok {
+		return errors.New("reactionSeverity must be none, mild, moderate or severe")
+	}
+	return nil
+}
+
+// hasReaction reports whether a reaction was observed after a food.
+func hasReaction(food models.FoodIntroduction) bool {
+	return reactionRanks[food.ReactionSeverity] > 0
+}
+
+// buildFoodExposures summarises chronologically ordered food introductions per
+// allergen category and per food. Foods are matched by name, ignoring case.
+func buildFoodExposures(foods []models.FoodIntroduction) gin.H {
+	type exposure struct {
+		first, last   time.Time
+		count         int
+		worstReaction string
+		name          string
+	}
+	record := func(exposures map[string]*exposure, key string, food models.FoodIntroduction) {
+		e, ok := exposures[key]
+		if !ok {
+			e = &exposure{first: food.Time, worstReaction: models.ReactionNone, name: food.Food}
+			exposures[key] = e
+		}
+		e.last = food.Time
+		e.count++
+		if reactionRanks[food.ReactionSeverity] > reactionRanks[e.worstReaction] {
+			e.worstReaction = food.ReactionSeverity
+		}
+	}
+
+	byAllergen := map[string]*exposure{}
+	byFood := map[string]*exposure{}
+	for _, food := range foods {
+		if food.AllergenCategory != "" {
+			record(byAllergen, food.AllergenCategory, food)
+		}
+		record(byFood, strings.ToLower(food.Food), food)
+	}
+
+	allergens := []gin.H{}
+	notIntroduced := []string{}
+	for _, category := range allergenCategories {
+		e, ok := byAllergen[category]
+		if !ok {
+			notIntroduced = append(notIntroduced, category)
+			continue
+		}
+		allergens = append(allergens, gin.H{
+			"allergenCategory": category,
+			"firstExposure":    e.first.Format(time.RFC3339),
+			"lastExposure":     e.last.Format(time.RFC3339),
+			"exposures":        e.count,
+			"worstReaction":    e.worstReaction,
+		})
+	}
+
+	// A food is only considered tolerated once it has been offered a few
+	// times, so foods tried once are worth offering again
+	triedOnce := []gin.H{}
+	for _, e := range byFood {
+		if e.count == 1 {
+			triedOnce = append(triedOnce, gin.H{
+				"food":          e.name,
+				"triedAt":       e.first.Format(time.RFC3339),
+				"worstReaction": e.worstReaction,
+			})
+		}
+	}
+	sort.Slice(triedOnce, func(i, j int) bool {
+		return triedOnce[i]["triedAt"].(string) < triedOnce[j]["triedAt"].(string)
+	})
+
+	return gin.H{
+		"allergens":     allergens,
+		"notIntroduced": notIntroduced,
+		"triedOnce":     triedOnce,
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var migrateTestDB sync.Once
+
+// setupTestDB points database.DB at the Postgres database of
+// TEST_DATABASE_URL, migrated like database.Connect does. Tests that need a
+// database are skipped without one.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	migrateTestDB.Do(func() {
+		db, err := gorm.Open(postgres.Open(dbURL), &gorm.Config{TranslateError: true})
+		if err != nil {
+			t.Fatalf("Failed to connect to database: %v", err)
+		}
+		if err := db.SetupJoinTable(&models.User{}, "Babies", &models.UserBaby{}); err != nil {
+			t.Fatalf("Failed to set up user_babies join table: %v", err)
+		}
+		if err := db.SetupJoinTable(&models.Baby{}, "Parents", &models.UserBaby{}); err != nil {
+			t.Fatalf("Failed to set up user_babies join table: %v", err)
+		}
+		if err := db.AutoMigrate(&models.User{}, &models.Baby{}, &models.UserBaby{}, &models.Sleep{}, &models.Diaper{}, &models.Nursing{}, &models.Measurement{}, &models.Invitation{}, &models.Session{}, &models.PasswordResetToken{}, &models.MilkStash{}, &models.MilkConsumption{}, &models.Medication{}, &models.MedicationDose{}, &models.Temperature{}, &models.IllnessEpisode{}, &models.Symptom{}, &models.Vaccination{}, &models.FoodIntroduction{}, &models.Milestone{}, &models.Attachment{}, &models.EventType{}, &models.CustomEvent{}, &models.Tombstone{}); err != nil {
+			t.Fatalf("Failed to migrate database: %v", err)
+		}
+		database.DB = db
+	})
+	if database.DB == nil {
+		t.Fatal("Test database is not set up")
+	}
+	t.Setenv("JWT_SECRET", "test-secret")
+}
+
+// newTestRouter mounts the routes like main.go does.
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	protected := r.Group("/api")
+	protected.Use(AuthMiddleware())
+	SetupUserRoutes(protected)
+	SetupSyncRoutes(protected)
+	return r
+}
+
+// createTestUser creates a user with a session and returns the user and an
+// access token for it.
+func createTestUser(t *testing.T, mustChangePassword bool) (models.User, string) {
+	t.Helper()
+	user := models.User{
+		ID:                 uuid.NewString(),
+		Username:           "user-" + uuid.NewString(),
+		Password:           "not a hash",
+		MustChangePassword: mustChangePassword,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:               uuid.NewString(),
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(uuid.NewString()),
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(time.Hour),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	token, err := generateToken(user.ID, session.ID)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	return user, token
+}
+
+// createTestBaby creates a baby owned by the user.
+func createTestBaby(t *testing.T, userID string) models.Baby {
+	t.Helper()
+	baby := models.Baby{
+		ID:              uuid.NewString(),
+		Name:            "Test baby",
+		Timezone:        "UTC",
+		DayStartHour:    1,
+		NightStartHour:  19,
+		NightEndHour:    7,
+		VaccineSchedule: "fr",
+	}
+	if err := database.DB.Create(&baby).Error; err != nil {
+		t.Fatalf("Failed to create baby: %v", err)
+	}
+	membership := models.UserBaby{UserID: userID, BabyID: baby.ID, Role: models.RoleOwner}
+	if err := database.DB.Create(&membership).Error; err != nil {
+		t.Fatalf("Failed to add baby member: %v", err)
+	}
+	return baby
+}
+
+// doRequest sends a request as the user of token, with body encoded as JSON
+// when it isn't nil, and decodes the JSON response into response.
+func doRequest(t *testing.T, r *gin.Engine, method, path, token string, headers map[string]string, body, response interface{}) int {
+	t.Helper()
+	reader := bytes.NewReader(nil)
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Failed to encode body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if response != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), response); err != nil {
+			t.Fatalf("Failed to decode response %q: %v", w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// idempotencyNamespace derives record IDs from Idempotency-Key headers.
+var idempotencyNamespace = uuid.MustParse("6f1d7c0e-2b8a-4c1e-9a57-3d0c9e4b8f21")
+
+// errIDTaken is returned when a client supplied ID belongs to a record of
+// another baby.
+var errIDTaken = errors.New("id is already used by another record")
+
+// errRecordDeleted is returned when a client supplied ID belongs to a record
+// that was created and then deleted.
+var errRecordDeleted = errors.New("the record was deleted")
+
+// recordID returns the ID of the record a create request inserts: the UUID
+// supplied by the client, one derived from the Idempotency-Key header, or a
+// new one. A retried request therefore always targets the same record. scope
+// keeps the same key sent to different endpoints apart.
+func recordID(c *gin.Context, id, scope string) (string, error) {
+	return keyedRecordID(c, id, c.GetHeader("Idempotency-Key"), scope)
+}
+
+// keyedRecordID is recordID with the idempotency key given explicitly, for
+// requests that carry one per record, like batch uploads.
+func keyedRecordID(c *gin.Context, id, key, scope string) (string, error) {
+	if id != "" {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return "", errors.New("id must be a UUID")
+		}
+		return parsed.String(), nil
+	}
+
+	if key != "" {
+		user := c.MustGet("user").(models.User)
+		return uuid.NewSHA1(idempotencyNamespace, []byte(user.ID+"\x00"+scope+"\x00"+key)).String(), nil
+	}
+
+	return uuid.NewString(), nil
+}
+
+// findCreated loads into record the record an earlier attempt of a create
+// already inserted with the ID. It reports false when the ID is unused.
+func findCreated(tx *gorm.DB, record interface{}, id, babyID string) (bool, error) {
+	err := tx.Where("id = ? AND baby_id = ?", id, babyID).First(record).Error
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	var deleted int64
+	if err := tx.Unscoped().Model(record).Where("id = ? AND baby_id = ?", id, babyID).Count(&deleted).Error; err != nil {
+		return false, err
+	}
+	if deleted > 0 {
+		return false, errRecordDeleted
+	}
+
+	var count int64
+	if err := tx.Unscoped().Model(record).Where("id = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, errIDTaken
+	}
+	return false, nil
+}
+
+// replayCreate answers a create request that an earlier attempt already
+// applied with the original record, loaded into record and formatted by
+// format. It reports whether a response was written, which is also the case
+// when the ID can't be used.
+func replayCreate(c *gin.Context, record interface{}, id, babyID string, format func() gin.H) bool {
+	found, err := findCreated(database.DB, record, id, babyID)
+	if errors.Is(err, errIDTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return true
+	}
+	if errors.Is(err, errRecordDeleted) {
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		return true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return true
+	}
+	if !found {
+		return false
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.JSON(http.StatusOK, format())
+	return true
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"baby-tracker/models"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestKeyedRecordID(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("user", models.User{ID: "user-1"})
+
+	first, err := keyedRecordID(c, "", "key-1", "diaper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := keyedRecordID(c, "", "key-1", "diaper"); again != first {
+		t.Errorf("same key and scope gave %s, then %s", first, again)
+	}
+	if other, _ := keyedRecordID(c, "", "key-1", "sleep"); other == first {
+		t.Errorf("same key in another scope gave the same ID %s", other)
+	}
+	if other, _ := keyedRecordID(c, "", "key-2", "diaper"); other == first {
+		t.Errorf("another key gave the same ID %s", other)
+	}
+	if fresh, _ := keyedRecordID(c, "", "", "diaper"); fresh == first {
+		t.Errorf("no key gave the keyed ID %s", fresh)
+	}
+
+	id := "0b7e4a4e-5d0f-4d8c-9f3a-2f5c8c1d9e6a"
+	if got, _ := keyedRecordID(c, id, "key-1", "diaper"); got != id {
+		t.Errorf("client ID %s became %s", id, got)
+	}
+	if _, err := keyedRecordID(c, "not-a-uuid", "", "diaper"); err == nil {
+		t.Error("invalid client ID accepted")
+	}
+}
@@ -0,0 +1,304 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func SetupIllnessRoutes(api *gin.RouterGroup) {
+	illness := api.Group("/illness")
+	illness.Use(AuthMiddleware()) // Add authentication middleware
+	{
+		// POST /api/illness - Start an illness episode
+		illness.POST("", checkBabyAccess(), func(c *gin.Context) {
+			var input episodeInput
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			episode := models.IllnessEpisode{ID: uuid.NewString(), BabyID: input.BabyID}
+			if err := input.apply(&episode); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if err := database.DB.Create(&episode).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, episode)
+		})
+
+		illness.GET("", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			var episodes []models.IllnessEpisode
+			if err := database.DB.Preload("Symptoms", func(db *gorm.DB) *gorm.DB {
+				return db.Order("time")
+			}).Where("baby_id = ?", babyID).Order("start DESC").Find(&episodes).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, episodes)
+		})
+
+		// PUT /api/illness/:id - Update an episode, e.g. to set its end once
+		// the baby is better
+		illness.PUT("/:id", checkRecordAccess("illness_episodes", "Illness episode"), func(c *gin.Context) {
+			var episode models.IllnessEpisode
+			if err := database.DB.First(&episode, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Illness episode not found"})
+				return
+			}
+
+			if !bindUpdate(c, &episode, &episodeInput{}) {
+				return
+			}
+
+			// Symptoms have their own endpoints
+			if err := database.DB.Omit("Symptoms").Save(&episode).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, episode)
+		})
+
+		illness.DELETE("/:id", checkRecordAccess("illness_episodes", "Illness episode"), func(c *gin.Context) {
+			var episode models.IllnessEpisode
+			if err := database.DB.First(&episode, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Illness episode not found"})
+				return
+			}
+
+			// Temperatures and doses are kept, they only fall within the episode
+			if err := database.DB.Transaction(func(tx *gorm.DB) error {
+				if err := deleteRecords(tx, &models.Symptom{}, "episode_id = ?", episode.ID); err != nil {
+					return err
+				}
+				return deleteRecords(tx, &models.IllnessEpisode{}, "id = ?", episode.ID)
+			}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+
+		// POST /api/illness/:id/symptoms - Record a symptom
+		illness.POST("/:id/symptoms", checkRecordAccess("illness_episodes", "Illness episode"), func(c *gin.Context) {
+			var symptomInput struct {
+				Time     string `json:"time"`
+				Name     string `json:"name" binding:"required"`
+				Severity string `json:"severity"`
+				Note     string `json:"note"`
+			}
+			if err := c.ShouldBindJSON(&symptomInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			var episode models.IllnessEpisode
+			if err := database.DB.First(&episode, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Illness episode not found"})
+				return
+			}
+
+			symptomTime := time.Now()
+			if symptomInput.Time != "" {
+				var err error
+				symptomTime, err = time.Parse(time.RFC3339, symptomInput.Time)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time format"})
+					return
+				}
+			}
+
+			switch symptomInput.Severity {
+			case "", "mild", "moderate", "severe":
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Severity must be mild, moderate or severe"})
+				return
+			}
+
+			symptom := models.Symptom{
+				ID:        uuid.NewString(),
+				EpisodeID: episode.ID,
+				BabyID:    episode.BabyID,
+				Time:      symptomTime.UTC(),
+				Name:      symptomInput.Name,
+				Severity:  symptomInput.Severity,
+				Note:      symptomInput.Note,
+			}
+			if err := database.DB.Create(&symptom).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, symptom)
+		})
+
+		illness.DELETE("/symptoms/:id", checkRecordAccess("symptoms", "Symptom"), func(c *gin.Context) {
+			var symptom models.Symptom
+			if err := database.DB.First(&symptom, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Symptom not found"})
+				return
+			}
+
+			if err := deleteRecords(database.DB, &models.Symptom{}, "id = ?", symptom.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+
+		// GET /api/illness/:id/summary - Everything recorded during an episode,
+		// in one place to show the doctor
+		illness.GET("/:id/summary", checkRecordAccess("illness_episodes", "Illness episode"), func(c *gin.Context) {
+			var episode models.IllnessEpisode
+			if err := database.DB.Preload("Symptoms", func(db *gorm.DB) *gorm.DB {
+				return db.Order("time")
+			}).First(&episode, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Illness episode not found"})
+				return
+			}
+
+			baby, ok := loadBaby(c, episode.BabyID)
+			if !ok {
+				return
+			}
+
+			summary, err := buildEpisodeSummary(baby, episode, time.Now())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, summary)
+		})
+	}
+}
+
+// episodeInput is the body of an illness episode create or update. BabyID is
+// only read on create.
+type episodeInput struct {
+	BabyID string `json:"babyId"`
+	Name   string `json:"name"`
+	Start  string `json:"start"`
+	End    string `json:"end"`
+	Note   string `json:"note"`
+}
+
+func (input episodeInput) apply(episode *models.IllnessEpisode) error {
+	start, err := time.Parse(time.RFC3339, input.Start)
+	if err != nil {
+		return errors.New("Invalid start time format")
+	}
+
+	episode.Name = input.Name
+	episode.Start = start.UTC()
+	episode.End = nil
+	if input.End != "" {
+		end, err := time.Parse(time.RFC3339, input.End)
+		if err != nil {
+			return errors.New("Invalid end time format")
+		}
+		endUTC := end.UTC()
+		episode.End = &endUTC
+	}
+	episode.Note = input.Note
+	return validateEpisode(*episode)
+}
+
+func validateEpisode(episode models.IllnessEpisode) error {
+	if episode.Start.IsZero() {
+		return errors.New("start is required")
+	}
+	if episode.End != nil && episode.End.Before(episode.Start) {
+		return errors.New("end must be after start")
+	}
+	return nil
+}
+
+// buildEpisodeSummary gathers the temperatures, medication doses and symptoms
+// recorded between the start and end of an episode.
+func buildEpisodeSummary(baby models.Baby, episode models.IllnessEpisode, now time.Time) (gin.H, error) {
+	end := now
+	if episode.End != nil {
+		end = *episode.End
+	}
+
+	var temperatures []models.Temperature
+	if err := database.DB.Where("baby_id = ? AND time >= ? AND time <= ?", baby.ID, episode.Start, end).
+		Order("time").Find(&temperatures).Error; err != nil {
+		return nil, err
+	}
+
+	var doses []models.MedicationDose
+	if err := database.DB.Where("baby_id = ? AND time >= ? AND time <= ?", baby.ID, episode.Start, end).
+		Order("time").Find(&doses).Error; err != nil {
+		return nil, err
+	}
+
+	var medications []models.Medication
+	if err := database.DB.Where("baby_id = ?", baby.ID).Find(&medications).Error; err != nil {
+		return nil, err
+	}
+	medicationNames := make(map[string]string, len(medications))
+	for _, medication := range medications {
+		medicationNames[medication.ID] = medication.Name
+	}
+
+	temperatureResponse := make([]gin.H, len(temperatures))
+	var peak gin.H
+	var peakCelsius float64
+	var feverReadings int
+	var firstFever, lastFever *time.Time
+	for i, temperature := range temperatures {
+		temperatureResponse[i] = formatTemperature(temperature, baby)
+		if temperature.Celsius > peakCelsius {
+			peakCelsius = temperature.Celsius
+			peak = temperatureResponse[i]
+		}
+		if flag := assessFever(temperature, baby); flag.Level == feverMild || flag.Level == feverHigh {
+			feverReadings++
+			if firstFever == nil {
+				firstFever = &temperatures[i].Time
+			}
+			lastFever = &temperatures[i].Time
+		}
+	}
+
+	doseResponse := make([]gin.H, len(doses))
+	doseCounts := make(map[string]int)
+	for i, dose := range doses {
+		doseResponse[i] = formatDose(dose, medicationNames[dose.MedicationID])
+		doseCounts[medicationNames[dose.MedicationID]]++
+	}
+
+	summary := gin.H{
+		"episode":       episode,
+		"babyName":      baby.Name,
+		"ongoing":       episode.End == nil,
+		"durationDays":  end.Sub(episode.Start).Hours() / 24,
+		"temperatures":  temperatureResponse,
+		"peak":          peak,
+		"feverReadings": feverReadings,
+		"firstFeverAt":  formatOptionalTime(firstFever),
+		"lastFeverAt":   formatOptionalTime(lastFever),
+		"medications":   doseResponse,
+		"doseCounts":    doseCounts,
+		"symptoms":      episode.Symptoms,
+	}
+	if baby.BirthDate != nil {
+		summary["ageDaysAtStart"] = int(episode.Start.Sub(*baby.BirthDate).Hours() / 24)
+	}
+	return summary, nil
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultInvitationTTL = 7 * 24 * time.Hour
+	maxInvitationTTL     = 30 * 24 * time.Hour
+)
+
+// invitationAlphabet leaves out characters that are easy to confuse when a
+// code is read out loud or typed from another screen.
+const invitationAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var (
+	errInvitationInvalid = errors.New("Invalid invitation code")
+	errInvitationUsed    = errors.New("Invitation has already been used")
+	errInvitationExpired = errors.New("Invitation has expired")
+	errInvitationUser    = errors.New("Invitation is for another user")
+	errAlreadyMember     = errors.New("You already have access to this baby")
+)
+
+func SetupInvitationRoutes(api *gin.RouterGroup) {
+	invitation := api.Group("/invitation")
+	invitation.Use(AuthMiddleware()) // Add authentication middleware
+	{
+		invitation.POST("", checkBabyAccess(), func(c *gin.Context) {
+			userInterface, _ := c.Get("user")
+			user := userInterface.(models.User)
+
+			var invitationInput struct {
+				BabyID         string `json:"babyId"`
+				Role           string `json:"role"`
+				Username       string `json:"username"`
+				ExpiresInHours int    `json:"expiresInHours"`
+			}
+			if err := c.ShouldBindJSON(&invitationInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Inviting people is part of managing sharing
+			if !requireBabyRole(c, invitationInput.BabyID, models.RoleOwner) {
+				return
+			}
+
+			if invitationInput.Role == "" {
+				invitationInput.Role = models.RoleCaregiver
+			}
+			if _, ok := roleRanks[invitationInput.Role]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be owner, caregiver or viewer"})
+				return
+			}
+
+			ttl := defaultInvitationTTL
+			if invitationInput.ExpiresInHours != 0 {
+				ttl = time.Duration(invitationInput.ExpiresInHours) * time.Hour
+			}
+			if ttl <= 0 || ttl > maxInvitationTTL {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "expiresInHours must be between 1 and 720"})
+				return
+			}
+
+			code, err := generateInvitationCode()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation code"})
+				return
+			}
+
+			invitation := models.Invitation{
+				ID:          uuid.NewString(),
+				Code:        code,
+				BabyID:      invitationInput.BabyID,
+				Role:        invitationInput.Role,
+				Username:    strings.TrimSpace(invitationInput.Username),
+				CreatedByID: user.ID,
+				ExpiresAt:   time.Now().Add(ttl).UTC(),
+			}
+			if err := database.DB.Create(&invitation).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, invitation)
+		})
+
+		// GET /api/invitation?babyId= - List pending invitations
+		invitation.GET("", func(c *gin.Context) {
+			babyID := c.Query("babyId")
+			if babyID == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Baby ID not provided"})
+				return
+			}
+
+			if !requireBabyRole(c, babyID, models.RoleOwner) {
+				return
+			}
+
+			var invitations []models.Invitation
+			if err := database.DB.Where("baby_id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?",
+				babyID, time.Now()).Order("created_at").Find(&invitations).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, invitations)
+		})
+
+		invitation.DELETE("/:id", func(c *gin.Context) {
+			var invitation models.Invitation
+			if err := database.DB.First(&invitation, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+				return
+			}
+
+			if !requireBabyRole(c, invitation.BabyID, models.RoleOwner) {
+				return
+			}
+
+			if invitation.AcceptedAt != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": errInvitationUsed.Error()})
+				return
+			}
+
+			now := time.Now().UTC()
+			invitation.RevokedAt = &now
+			if err := database.DB.Save(&invitation).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+
+		invitation.POST("/accept", func(c *gin.Context) {
+			userInterface, _ := c.Get("user")
+			user := userInterface.(models.User)
+
+			var acceptInput struct {
+				Code string `json:"code" binding:"required"`
+			}
+			if err := c.ShouldBindJSON(&acceptInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			membership, err := acceptInvitation(user, acceptInput.Code)
+			if err != nil {
+				switch {
+				case errors.Is(err, errInvitationInvalid):
+					c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				case errors.Is(err, errInvitationUser):
+					c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				case errors.Is(err, errInvitationUsed), errors.Is(err, errInvitationExpired), errors.Is(err, errAlreadyMember):
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				default:
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				}
+				return
+			}
+
+			var baby models.Baby
+			if err := database.DB.First(&baby, "id = ?", membership.BabyID).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			baby.Role = membership.Role
+
+			c.JSON(http.StatusOK, baby)
+		})
+	}
+}
+
+// acceptInvitation consumes an invitation code and adds the user to the baby
+// with the invited role. The invitation row is locked so that a code can't be
+// redeemed twice concurrently.
+func acceptInvitation(user models.User, code string) (models.UserBaby, error) {
+	var membership models.UserBaby
+	code = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var invitation models.Invitation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&invitation, "code = ?", code).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvitationInvalid
+			}
+			return err
+		}
+
+		if invitation.RevokedAt != nil {
+			return errInvitationInvalid
+		}
+		if invitation.AcceptedAt != nil {
+			return errInvitationUsed
+		}
+		if time.Now().After(invitation.ExpiresAt) {
+			return errInvitationExpired
+		}
+		if invitation.Username != "" && !strings.EqualFold(invitation.Username, user.Username) {
+			return errInvitationUser
+		}
+
+		var count int64
+		if err := tx.Model(&models.UserBaby{}).Where("user_id = ? AND baby_id = ?", user.ID, invitation.BabyID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errAlreadyMember
+		}
+
+		membership = models.UserBaby{UserID: user.ID, BabyID: invitation.BabyID, Role: invitation.Role}
+		if err := tx.Create(&membership).Error; err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		invitation.AcceptedAt = &now
+		invitation.AcceptedByID = &user.ID
+		return tx.Save(&invitation).Error
+	})
+
+	return membership, err
+}
+
+// generateInvitationCode returns a random 8 character code. It is stored
+// without separators; clients may display it as two groups of four.
+func generateInvitationCode() (string, error) {
+	max := big.NewInt(int64(len(invitationAlphabet)))
+	code := make([]byte, 8)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = invitationAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/growth"
+	"baby-tracker/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// growthPercentiles are the reference curves returned with the growth series.
+var growthPercentiles = []float64{3, 15, 50, 85, 97}
+
+func SetupMeasurementRoutes(api *gin.RouterGroup) {
+	measurement := api.Group("/measurement")
+	measurement.Use(AuthMiddleware()) // Add authentication middleware
+	{
+		measurement.POST("", checkBabyAccess(), func(c *gin.Context) {
+			var input measurementInput
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			measurement := models.Measurement{ID: uuid.NewString(), BabyID: input.BabyID}
+			if err := input.apply(&measurement); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if err := database.DB.Create(&measurement).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, measurement)
+		})
+
+		measurement.GET("", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			var measurements []models.Measurement
+			if err := database.DB.Where("baby_id = ?", babyID).Order("time").Find(&measurements).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, measurements)
+		})
+
+		measurement.GET("/growth", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
+				return
+			}
+
+			if baby.BirthDate == nil || baby.Sex == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Baby birth date and sex are required for growth percentiles"})
+				return
+			}
+
+			var measurements []models.Measurement
+			if err := database.DB.Where("baby_id = ?", babyID).Order("time").Find(&measurements).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, buildGrowthReport(baby, measurements, time.Now()))
+		})
+
+		measurement.PUT("/:id", checkRecordAccess("measurements", "Measurement"), func(c *gin.Context) {
+			var measurement models.Measurement
+			if err := database.DB.First(&measurement, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Measurement not found"})
+				return
+			}
+
+			if !bindUpdate(c, &measurement, &measurementInput{}) {
+				return
+			}
+
+			if err := database.DB.Save(&measurement).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, measurement)
+		})
+
+		measurement.DELETE("/:id", checkRecordAccess("measurements", "Measurement"), func(c *gin.Context) {
+			var measurement models.Measurement
+			if err := database.DB.First(&measurement, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Measurement not found"})
+				return
+			}
+
+			if err := deleteRecords(database.DB, &models.Measurement{}, "id = ?", measurement.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+	}
+}
+
+// measurementInput is the body of a measurement create or update. BabyID is
+// only read on create.
+type measurementInput struct {
+	Time                string   `json:"time"`
+	WeightKg            *float64 `json:"weightKg"`
+	LengthCm            *float64 `json:"lengthCm"`
+	HeadCircumferenceCm *float64 `json:"headCircumferenceCm"`
+	BabyID              string   `json:"babyId"`
+	Note                string   `json:"note"`
+}
+
+func (input measurementInput) apply(measurement *models.Measurement) error {
+	measurementTime, err := time.Parse(time.RFC3339, input.Time)
+	if err != nil {
+		return errors.New("Invalid time format")
+	}
+
+	measurement.Time = measurementTime.UTC()
+	measurement.WeightKg = input.WeightKg
+	measurement.LengthCm = input.LengthCm
+	measurement.HeadCircumferenceCm = input.HeadCircumferenceCm
+	measurement.Note = input.Note
+	return validateMeasurement(*measurement)
+}
+
+func validateMeasurement(measurement models.Measurement) error {
+	if measurement.WeightKg == nil && measurement.LengthCm == nil && measurement.HeadCircumferenceCm == nil {
+		return errors.New("at least one of weightKg, lengthCm or headCircumferenceCm is required")
+	}
+	for _, value := range []*float64{measurement.WeightKg, measurement.LengthCm, measurement.HeadCircumferenceCm} {
+		if value != nil && *value <= 0 {
+			return errors.New("measurements must be positive")
+		}
+	}
+	return nil
+}
+
+// measurementValue returns the value a measurement recorded for an indicator.
+func measurementValue(measurement models.Measurement, indicator growth.Indicator) *float64 {
+	switch indicator {
+	case growth.Weight:
+		return measurement.WeightKg
+	case growth.Length:
+		return measurement.LengthCm
+	case growth.HeadCircumference:
+		return measurement.HeadCircumferenceCm
+	}
+	return nil
+}
+
+// buildGrowthReport scores every measurement against the WHO standards and adds
+// reference percentile curves for each month of the baby's life so far.
+func buildGrowthReport(baby models.Baby, measurements []models.Measurement, now time.Time) gin.H {
+	birth := *baby.BirthDate
+
+	series := make([]gin.H, len(measurements))
+	lastTime := now
+	for i, measurement := range measurements {
+		ageDays := measurement.Time.Sub(birth).Hours() / 24
+		point := gin.H{
+			"id":      measurement.ID,
+			"time":    measurement.Time.Format(time.RFC3339),
+			"ageDays": int(ageDays),
+		}
+		for _, indicator := range growth.Indicators {
+			value := measurementValue(measurement, indicator)
+			if value == nil {
+				continue
+			}
+			entry := gin.H{"value": *value, "unit": growth.Unit(indicator), "zScore": nil, "percentile": nil}
+			if z, err := growth.ZScore(indicator, baby.Sex, ageDays, *value); err == nil {
+				entry["zScore"] = z
+				entry["percentile"] = growth.Percentile(z)
+			}
+			point[string(indicator)] = entry
+		}
+		series[i] = point
+		if measurement.Time.After(lastTime) {
+			lastTime = measurement.Time
+		}
+	}
+
+	reference := gin.H{}
+	maxAgeDays := lastTime.Sub(birth).Hours() / 24
+	for _, indicator := range growth.Indicators {
+		var curve []gin.H
+		for month := 0; ; month++ {
+			date := birth.AddDate(0, month, 0)
+			ageDays := date.Sub(birth).Hours() / 24
+			if ageDays > maxAgeDays+31 || ageDays > growth.MaxAgeDays(indicator) {
+				break
+			}
+			point := gin.H{"month": month, "date": date.Format(time.RFC3339)}
+			for _, p := range growthPercentiles {
+				if value, err := growth.ValueAt(indicator, baby.Sex, ageDays, growth.ZForPercentile(p)); err == nil {
+					point[fmt.Sprintf("p%g", p)] = value
+				}
+			}
+			curve = append(curve, point)
+		}
+		reference[string(indicator)] = curve
+	}
+
+	return gin.H{
+		"babyId":    baby.ID,
+		"birthDate": birth.Format(time.RFC3339),
+		"sex":       baby.Sex,
+		"series":    series,
+		"reference": reference,
+	}
+}
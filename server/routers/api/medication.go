@@ -0,0 +1,381 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func SetupMedicationRoutes(api *gin.RouterGroup) {
+	medication := api.Group("/medication")
+	medication.Use(AuthMiddleware()) // Add authentication middleware
+	{
+		// POST /api/medication - Define a medication for a baby
+		medication.POST("", checkBabyAccess(), func(c *gin.Context) {
+			var input medicationInput
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			medication := models.Medication{ID: uuid.NewString(), BabyID: input.BabyID}
+			if err := input.apply(&medication); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			medication.Active = true
+
+			if err := database.DB.Create(&medication).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, medication)
+		})
+
+		// GET /api/medication?babyId= - List a baby's medications and when the
+		// next dose may be given
+		medication.GET("", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			query := database.DB.Where("baby_id = ?", babyID)
+			if c.Query("includeInactive") != "true" {
+				query = query.Where("active = ?", true)
+			}
+			var medications []models.Medication
+			if err := query.Order("name").Find(&medications).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			now := time.Now()
+			response := make([]gin.H, len(medications))
+			for i, medication := range medications {
+				var doses []models.MedicationDose
+				if err := database.DB.Where("medication_id = ? AND time > ? AND time <= ?", medication.ID, now.Add(-24*time.Hour), now).
+					Order("time").Find(&doses).Error; err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+
+				var lastDose *time.Time
+				if len(doses) > 0 {
+					lastDose = &doses[len(doses)-1].Time
+				}
+				response[i] = gin.H{
+					"medication":   medication,
+					"lastDoseAt":   formatOptionalTime(lastDose),
+					"dosesLast24h": len(doses),
+					"nextDoseAt":   nextDoseTime(medication, doses, now).Format(time.RFC3339),
+				}
+			}
+			c.JSON(http.StatusOK, response)
+		})
+
+		medication.PUT("/:id", checkRecordAccess("medications", "Medication"), func(c *gin.Context) {
+			var medication models.Medication
+			if err := database.DB.First(&medication, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Medication not found"})
+				return
+			}
+
+			if !bindUpdate(c, &medication, &medicationInput{}) {
+				return
+			}
+
+			if err := database.DB.Save(&medication).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, medication)
+		})
+
+		// DELETE /api/medication/:id - Delete a medication and its dose history.
+		// Set active to false instead to keep the history.
+		medication.DELETE("/:id", checkRecordAccess("medications", "Medication"), func(c *gin.Context) {
+			var medication models.Medication
+			if err := database.DB.First(&medication, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Medication not found"})
+				return
+			}
+
+			if err := database.DB.Transaction(func(tx *gorm.DB) error {
+				if err := deleteRecords(tx, &models.MedicationDose{}, "medication_id = ?", medication.ID); err != nil {
+					return err
+				}
+				return deleteRecords(tx, &models.Medication{}, "id = ?", medication.ID)
+			}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+
+		// GET /api/medication/:id/doses - Dose history, most recent first
+		medication.GET("/:id/doses", checkRecordAccess("medications", "Medication"), func(c *gin.Context) {
+			var medication models.Medication
+			if err := database.DB.First(&medication, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Medication not found"})
+				return
+			}
+
+			var doses []models.MedicationDose
+			if err := database.DB.Where("medication_id = ?", medication.ID).Order("time DESC").Find(&doses).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			response := make([]gin.H, len(doses))
+			for i, dose := range doses {
+				response[i] = formatDose(dose, medication.Name)
+			}
+			c.JSON(http.StatusOK, response)
+		})
+
+		// POST /api/medication/:id/doses - Log a dose. A dose breaking the
+		// minimum interval or the daily maximum is refused unless force is set.
+		medication.POST("/:id/doses", checkRecordAccess("medications", "Medication"), func(c *gin.Context) {
+			var doseInput struct {
+				Time  string   `json:"time"`
+				Dose  *float64 `json:"dose"`
+				Note  string   `json:"note"`
+				Force bool     `json:"force"`
+			}
+			if err := bindOptionalJSON(c, &doseInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			var medication models.Medication
+			if err := database.DB.First(&medication, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Medication not found"})
+				return
+			}
+
+			doseTime := time.Now()
+			if doseInput.Time != "" {
+				var err error
+				doseTime, err = time.Parse(time.RFC3339, doseInput.Time)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time format"})
+					return
+				}
+			}
+
+			userInterface, _ := c.Get("user")
+			dose := models.MedicationDose{
+				ID:           uuid.NewString(),
+				MedicationID: medication.ID,
+				BabyID:       medication.BabyID,
+				Time:         doseTime.UTC(),
+				Dose:         medication.Dose,
+				Unit:         medication.Unit,
+				GivenByID:    userInterface.(models.User).ID,
+				Note:         doseInput.Note,
+			}
+			if doseInput.Dose != nil {
+				if *doseInput.Dose <= 0 {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Dose must be positive"})
+					return
+				}
+				dose.Dose = *doseInput.Dose
+			}
+
+			var warnings []string
+			errDoseRefused := errors.New("dose refused")
+			err := database.DB.Transaction(func(tx *gorm.DB) error {
+				// Lock the medication so two parents logging at once are checked
+				// against each other's dose
+				if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&medication, "id = ?", medication.ID).Error; err != nil {
+					return err
+				}
+
+				var err error
+				warnings, err = doseWarnings(tx, medication, dose.Time)
+				if err != nil {
+					return err
+				}
+				if len(warnings) > 0 && !doseInput.Force {
+					return errDoseRefused
+				}
+
+				dose.Overridden = len(warnings) > 0
+				return tx.Create(&dose).Error
+			})
+			if errors.Is(err, errDoseRefused) {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":    "Dose breaks the dosing schedule, set force to log it anyway",
+					"warnings": warnings,
+				})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			response := formatDose(dose, medication.Name)
+			response["warnings"] = warnings
+			c.JSON(http.StatusOK, response)
+		})
+
+		medication.DELETE("/doses/:id", checkRecordAccess("medication_doses", "Dose"), func(c *gin.Context) {
+			var dose models.MedicationDose
+			if err := database.DB.First(&dose, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Dose not found"})
+				return
+			}
+
+			if err := deleteRecords(database.DB, &models.MedicationDose{}, "id = ?", dose.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+	}
+}
+
+// medicationInput is the body of a medication create or update. BabyID is
+// only read on create, and new medications are always active.
+type medicationInput struct {
+	BabyID           string  `json:"babyId"`
+	Name             string  `json:"name"`
+	Dose             float64 `json:"dose"`
+	Unit             string  `json:"unit"`
+	MinIntervalHours float64 `json:"minIntervalHours"`
+	MaxPerDay        int     `json:"maxPerDay"`
+	Active           *bool   `json:"active"` // kept as is when left out
+	Note             string  `json:"note"`
+}
+
+func (input medicationInput) apply(medication *models.Medication) error {
+	medication.Name = input.Name
+	medication.Dose = input.Dose
+	medication.Unit = input.Unit
+	medication.MinIntervalHours = input.MinIntervalHours
+	medication.MaxPerDay = input.MaxPerDay
+	if input.Active != nil {
+		medication.Active = *input.Active
+	}
+	medication.Note = input.Note
+	return validateMedication(*medication)
+}
+
+func validateMedication(medication models.Medication) error {
+	if medication.Name == "" {
+		return errors.New("name is required")
+	}
+	if medication.Dose < 0 {
+		return errors.New("dose cannot be negative")
+	}
+	if medication.MinIntervalHours < 0 {
+		return errors.New("minIntervalHours cannot be negative")
+	}
+	if medication.MaxPerDay < 0 {
+		return errors.New("maxPerDay cannot be negative")
+	}
+	return nil
+}
+
+// formatDose converts a dose to its API representation.
+func formatDose(dose models.MedicationDose, medicationName string) gin.H {
+	return gin.H{
+		"id":             dose.ID,
+		"medicationId":   dose.MedicationID,
+		"medicationName": medicationName,
+		"time":           dose.Time.Format(time.RFC3339),
+		"dose":           dose.Dose,
+		"unit":           dose.Unit,
+		"givenById":      dose.GivenByID,
+		"overridden":     dose.Overridden,
+		"babyId":         dose.BabyID,
+		"note":           dose.Note,
+	}
+}
+
+// doseWarnings lists the dosing limits a dose of medication given at t would
+// break. Doses logged after t count too, since doses are often logged late.
+func doseWarnings(tx *gorm.DB, medication models.Medication, t time.Time) ([]string, error) {
+	var doses []models.MedicationDose
+	if err := tx.Where("medication_id = ? AND time > ? AND time < ?", medication.ID, t.Add(-24*time.Hour), t.Add(24*time.Hour)).
+		Order("time").Find(&doses).Error; err != nil {
+		return nil, err
+	}
+	return checkDoseLimits(medication, doses, t), nil
+}
+
+// checkDoseLimits returns the warnings for a dose at t given the other doses
+// within 24 hours of it.
+func checkDoseLimits(medication models.Medication, doses []models.MedicationDose, t time.Time) []string {
+	warnings := []string{}
+	minInterval := time.Duration(medication.MinIntervalHours * float64(time.Hour))
+	for _, dose := range doses {
+		gap := t.Sub(dose.Time)
+		if gap < 0 {
+			gap = -gap
+		}
+		if minInterval > 0 && gap < minInterval {
+			warnings = append(warnings, fmt.Sprintf("A dose was logged at %s, less than %g hours apart",
+				dose.Time.Format(time.RFC3339), medication.MinIntervalHours))
+		}
+	}
+
+	if medication.MaxPerDay > 0 {
+		// Check every 24 hour window containing t, each starting at a dose
+		times := []time.Time{t}
+		for _, dose := range doses {
+			times = append(times, dose.Time)
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+		for _, windowStart := range times {
+			if windowStart.After(t) {
+				break
+			}
+			count := 0
+			for _, doseTime := range times {
+				if !doseTime.Before(windowStart) && doseTime.Before(windowStart.Add(24*time.Hour)) {
+					count++
+				}
+			}
+			if count > medication.MaxPerDay {
+				warnings = append(warnings, fmt.Sprintf("This would be dose %d within 24 hours, the maximum is %d",
+					count, medication.MaxPerDay))
+				break
+			}
+		}
+	}
+
+	return warnings
+}
+
+// nextDoseTime returns the earliest time from now a new dose respects both
+// limits, given the doses of the last 24 hours in chronological order.
+func nextDoseTime(medication models.Medication, doses []models.MedicationDose, now time.Time) time.Time {
+	next := now
+	if len(doses) == 0 {
+		return next
+	}
+
+	last := doses[len(doses)-1].Time
+	if interval := last.Add(time.Duration(medication.MinIntervalHours * float64(time.Hour))); interval.After(next) {
+		next = interval
+	}
+	// Once the daily maximum is reached, wait for the oldest counted dose to
+	// leave the 24 hour window
+	if medication.MaxPerDay > 0 && len(doses) >= medication.MaxPerDay {
+		oldest := doses[len(doses)-medication.MaxPerDay].Time.Add(24 * time.Hour)
+		if oldest.After(next) {
+			next = oldest
+		}
+	}
+	return next
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"baby-tracker/models"
+	"testing"
+	"time"
+)
+
+func TestCheckDoseLimits(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(hours float64) models.MedicationDose {
+		return models.MedicationDose{Time: now.Add(time.Duration(hours * float64(time.Hour)))}
+	}
+	paracetamol := models.Medication{MinIntervalHours: 6, MaxPerDay: 4}
+
+	tests := []struct {
+		name       string
+		medication models.Medication
+		doses      []models.MedicationDose
+		want       []string
+	}{
+		{"first dose", paracetamol, nil, []string{}},
+		{"after the interval", paracetamol, []models.MedicationDose{at(-6)}, []string{}},
+		{"too soon", paracetamol, []models.MedicationDose{at(-4)}, []string{
+			"A dose was logged at 2024-03-01T08:00:00Z, less than 6 hours apart",
+		}},
+		{"too close to a later dose", paracetamol, []models.MedicationDose{at(2)}, []string{
+			"A dose was logged at 2024-03-01T14:00:00Z, less than 6 hours apart",
+		}},
+		{"no interval", models.Medication{MaxPerDay: 4}, []models.MedicationDose{at(-1)}, []string{}},
+		{"daily maximum reached", paracetamol, []models.MedicationDose{at(-23), at(-17), at(-11), at(-6)}, []string{
+			"This would be dose 5 within 24 hours, the maximum is 4",
+		}},
+		{"oldest dose out of the window", paracetamol, []models.MedicationDose{at(-24), at(-18), at(-12), at(-6)}, []string{}},
+		// Doses are often logged late, so doses after it count too
+		{"later doses count", paracetamol, []models.MedicationDose{at(-12), at(-6), at(6), at(11)}, []string{
+			"This would be dose 5 within 24 hours, the maximum is 4",
+		}},
+		{"no daily maximum", models.Medication{MinIntervalHours: 1}, []models.MedicationDose{at(-5), at(-4), at(-3), at(-2)}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkDoseLimits(tt.medication, tt.doses, now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("warnings = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("warnings = %q, want %q", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestNextDoseTime(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(hours float64) models.MedicationDose {
+		return models.MedicationDose{Time: now.Add(time.Duration(hours * float64(time.Hour)))}
+	}
+	paracetamol := models.Medication{MinIntervalHours: 6, MaxPerDay: 4}
+
+	tests := []struct {
+		name       string
+		medication models.Medication
+		doses      []models.MedicationDose
+		want       time.Time
+	}{
+		{"no doses", paracetamol, nil, now},
+		{"interval passed", paracetamol, []models.MedicationDose{at(-8)}, now},
+		{"waits for the interval", paracetamol, []models.MedicationDose{at(-2)}, now.Add(4 * time.Hour)},
+		{"waits for the oldest dose to leave the window", paracetamol, []models.MedicationDose{at(-20), at(-14), at(-10), at(-7)}, now.Add(4 * time.Hour)},
+		{"interval outlasts the window", paracetamol, []models.MedicationDose{at(-23), at(-16), at(-10), at(-1)}, now.Add(5 * time.Hour)},
+		{"no limits", models.Medication{}, []models.MedicationDose{at(-1)}, now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextDoseTime(tt.medication, tt.doses, now); !got.Equal(tt.want) {
+				t.Errorf("nextDoseTime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"baby-tracker/database"
 	"baby-tracker/models"
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"io"
@@ -13,80 +14,100 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"gorm.io/gorm"
 )
 
-func checkBabyAccess() gin.HandlerFunc {
+// babyAccess returns a middleware that checks the user's role for the baby
+// found by resolve against the role the request's HTTP method needs, so that
+// handlers behind it don't check access themselves. resolve writes the error
+// response and returns false when the request doesn't name a baby.
+func babyAccess(resolve func(c *gin.Context) (string, bool)) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get user from context (set by AuthMiddleware)
-		userInterface, exists := c.Get("user")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		babyID, ok := resolve(c)
+		if !ok {
 			c.Abort()
 			return
 		}
 
-		user := userInterface.(models.User)
-
-		// Reload user with babies
-		if err := database.DB.Preload("Babies").First(&user, "id = ?", user.ID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-				c.Abort()
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if !requireBabyAccess(c, babyID) {
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		if c.Request.Method == "POST" {
-			// Read the body
-			bodyBytes, err := c.GetRawData()
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-				c.Abort()
-				return
+// checkBabyAccess checks access to the baby of the babyId field of the JSON
+// body, or of the babyId query parameter for requests without a body.
+func checkBabyAccess() gin.HandlerFunc {
+	return babyAccess(func(c *gin.Context) (string, bool) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodDelete:
+			babyID := c.Query("babyId")
+			if babyID == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Baby ID not provided"})
+				return "", false
 			}
+			return babyID, true
+		}
 
-			// Parse the body
-			var body map[string]interface{}
-			if err := json.Unmarshal(bodyBytes, &body); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-				c.Abort()
-				return
-			}
+		// Read the body
+		bodyBytes, err := c.GetRawData()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return "", false
+		}
+		// Restore the body for the next handler
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
-			// Check babyId
-			babyID, ok := body["babyId"].(string)
-			if !ok {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Baby ID not provided"})
-				c.Abort()
-				return
-			}
+		// Parse the body
+		var body map[string]interface{}
+		if err := json.Unmarshal(bodyBytes, &body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return "", false
+		}
 
-			hasAccess := false
-			for _, baby := range user.Babies {
-				if baby.ID == babyID {
-					hasAccess = true
-					break
-				}
-			}
+		// Check babyId
+		babyID, ok := body["babyId"].(string)
+		if !ok || babyID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Baby ID not provided"})
+			return "", false
+		}
+		return babyID, true
+	})
+}
 
-			if !hasAccess {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this baby"})
-				c.Abort()
-				return
-			}
+// checkBabyParamAccess checks access to the baby whose ID is the :id path
+// parameter.
+func checkBabyParamAccess() gin.HandlerFunc {
+	return babyAccess(func(c *gin.Context) (string, bool) {
+		return c.Param("id"), true
+	})
+}
 
-			// Restore the body for the next handler
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+// checkRecordAccess checks access to the baby owning the record of table
+// whose ID is the :id path parameter. Records in the trash are included, so
+// that they can be restored; handlers still answer 404 for them otherwise.
+func checkRecordAccess(table, name string) gin.HandlerFunc {
+	return babyAccess(func(c *gin.Context) (string, bool) {
+		var babyID string
+		if err := database.DB.Table(table).Select("baby_id").Where("id = ?", c.Param("id")).Row().Scan(&babyID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": name + " not found"})
+				return "", false
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return "", false
 		}
+		return babyID, true
+	})
+}
 
-		// Store user with babies in context for later use
-		c.Set("user", user)
-		c.Next()
-	}
+// allowedDuringPasswordChange lists the routes, by method and path, a user who
+// must change their password can still call.
+var allowedDuringPasswordChange = map[string]bool{
+	"POST /auth/password": true,
+	"POST /auth/logout":   true,
+	"GET /api/user":       true,
 }
 
 func AuthMiddleware() gin.HandlerFunc {
@@ -124,6 +145,16 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+			// Tokens are only valid while their session is
+			sessionID, _ := claims["sid"].(string)
+			var session models.Session
+			if err := database.DB.First(&session, "id = ?", sessionID).Error; err != nil ||
+				session.RevokedAt != nil || session.UserID != claims["sub"] {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+				c.Abort()
+				return
+			}
+
 			// Get user from database
 			var user models.User
 			if err := database.DB.First(&user, "id = ?", claims["sub"]).Error; err != nil {
@@ -132,8 +163,16 @@ func AuthMiddleware() gin.HandlerFunc {
 				return
 			}
 
-			// Set user in context
+			// Accounts flagged for a password change can't do anything else
+			if user.MustChangePassword && !allowedDuringPasswordChange[c.Request.Method+" "+c.FullPath()] {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Password change required", "mustChangePassword": true})
+				c.Abort()
+				return
+			}
+
+			// Set user and session in context
 			c.Set("user", user)
+			c.Set("sessionID", session.ID)
 			c.Next()
 		} else {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
@@ -142,3 +181,94 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 	}
 }
+
+// roleRanks orders roles so that a higher rank includes the lower ones.
+var roleRanks = map[string]int{
+	models.RoleViewer:    1,
+	models.RoleCaregiver: 2,
+	models.RoleOwner:     3,
+}
+
+func roleAtLeast(role, minRole string) bool {
+	return roleRanks[role] >= roleRanks[minRole]
+}
+
+// methodRole returns the minimum role needed to call an event endpoint with
+// the given HTTP method: viewers can read, caregivers can also write.
+func methodRole(method string) string {
+	if method == http.MethodGet || method == http.MethodHead {
+		return models.RoleViewer
+	}
+	return models.RoleCaregiver
+}
+
+// babyRole returns the user's role for the baby, or "" if they aren't a member.
+func babyRole(userID, babyID string) (string, error) {
+	var membership models.UserBaby
+	err := database.DB.Where("user_id = ? AND baby_id = ?", userID, babyID).Limit(1).Find(&membership).Error
+	return membership.Role, err
+}
+
+// requireBabyRole checks that the user in context holds at least minRole for
+// the baby. It writes the error response itself and returns false when access
+// is denied.
+func requireBabyRole(c *gin.Context, babyID string, minRole string) bool {
+	userInterface, _ := c.Get("user")
+	user := userInterface.(models.User)
+
+	role, err := babyRole(user.ID, babyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if role == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this baby"})
+		return false
+	}
+
+	if !roleAtLeast(role, minRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your role doesn't allow this action"})
+		return false
+	}
+
+	return true
+}
+
+// requireBabyAccess checks the user's role for the baby against the role the
+// request's HTTP method needs.
+func requireBabyAccess(c *gin.Context, babyID string) bool {
+	return requireBabyRole(c, babyID, methodRole(c.Request.Method))
+}
+
+// bindOptionalJSON binds the request body into obj when one was sent, so
+// endpoints like the timer stop calls can be invoked with an empty body.
+func bindOptionalJSON(c *gin.Context, obj interface{}) error {
+	if c.Request.ContentLength == 0 {
+		return nil
+	}
+	return c.ShouldBindJSON(obj)
+}
+
+// recordInput is the body of a create or update request: the fields clients
+// may set on a record, which apply validates and copies onto it.
+type recordInput[T any] interface {
+	apply(record *T) error
+}
+
+// bindUpdate binds the body of an update into input and applies it to
+// record, as loaded from the database. Only the fields of input change, so
+// the record keeps its ID, its baby and the timestamps the server controls.
+// It writes the error response itself and returns false when the body is
+// invalid.
+func bindUpdate[T any](c *gin.Context, record *T, input recordInput[T]) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err := input.apply(record); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func SetupMilestoneRoutes(api *gin.RouterGroup) {
+	milestone := api.Group("/milestone")
+	milestone.Use(AuthMiddleware()) // Add authentication middleware
+	{
+		milestone.POST("", checkBabyAccess(), func(c *gin.Context) {
+			var input milestoneInput
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			milestone := models.Milestone{
+				ID:          uuid.NewString(),
+				BabyID:      input.BabyID,
+				Attachments: []models.Attachment{},
+			}
+			if err := input.apply(&milestone); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if err := database.DB.Create(&milestone).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, milestone)
+		})
+
+		milestone.GET("", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			milestones, err := loadMilestones(babyID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, milestones)
+		})
+
+		// GET /api/milestone/timeline?babyId= - Milestones grouped by the
+		// baby's age in months, or by calendar month without a birth date
+		milestone.GET("/timeline", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
+				return
+			}
+
+			milestones, err := loadMilestones(babyID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, buildMilestoneTimeline(baby, milestones))
+		})
+
+		milestone.PUT("/:id", checkRecordAccess("milestones", "Milestone"), func(c *gin.Context) {
+			var milestone models.Milestone
+			if err := database.DB.First(&milestone, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Milestone not found"})
+				return
+			}
+
+			if !bindUpdate(c, &milestone, &milestoneInput{}) {
+				return
+			}
+
+			// Attachments have their own endpoints
+			if err := database.DB.Omit("Attachments").Save(&milestone).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, milestone)
+		})
+
+		milestone.DELETE("/:id", checkRecordAccess("milestones", "Milestone"), func(c *gin.Context) {
+			var milestone models.Milestone
+			if err := database.DB.First(&milestone, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Milestone not found"})
+				return
+			}
+
+			if err := deleteWithAttachments("milestones", milestone.ID, func(tx *gorm.DB) error {
+				return deleteRecords(tx, &models.Milestone{}, "id = ?", milestone.ID)
+			}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+	}
+}
+
+// milestoneInput is the body of a milestone create or update. BabyID is only
+// read on create.
+type milestoneInput struct {
+	BabyID   string `json:"babyId"`
+	Date     string `json:"date"`
+	Title    string `json:"title"`
+	Category string `json:"category"`
+	Note     string `json:"note"`
+}
+
+func (input milestoneInput) apply(milestone *models.Milestone) error {
+	date, err := time.Parse(time.RFC3339, input.Date)
+	if err != nil {
+		return errors.New("Invalid date format")
+	}
+
+	milestone.Date = date.UTC()
+	milestone.Title = input.Title
+	milestone.Category = input.Category
+	milestone.Note = input.Note
+	return validateMilestone(*milestone)
+}
+
+func validateMilestone(milestone models.Milestone) error {
+	if milestone.Title == "" {
+		return errors.New("title is required")
+	}
+	switch milestone.Category {
+	case "", "motor", "social", "language", "cognitive":
+	default:
+		return errors.New("category must be motor, social, language or cognitive")
+	}
+	return nil
+}
+
+// loadMilestones returns a baby's milestones in date order with their
+// attachments.
+func loadMilestones(babyID string) ([]models.Milestone, error) {
+	var milestones []models.Milestone
+	err := database.DB.Preload("Attachments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at")
+	}).Where("baby_id = ?", babyID).Order("date").Find(&milestones).Error
+	return milestones, err
+}
+
+// buildMilestoneTimeline groups date ordered milestones by the baby's age in
+// whole months, or by calendar month when the birth date is unknown.
+func buildMilestoneTimeline(baby models.Baby, milestones []models.Milestone) []gin.H {
+	timeline := []gin.H{}
+	var current gin.H
+	var currentKey interface{}
+	for _, milestone := range milestones {
+		entry := gin.H{"milestone": milestone}
+		var key interface{} = milestone.Date.Format("2006-01")
+		if baby.BirthDate != nil {
+			key = monthsBetween(*baby.BirthDate, milestone.Date)
+			entry["ageDays"] = int(milestone.Date.Sub(*baby.BirthDate).Hours() / 24)
+		}
+
+		if current == nil || key != currentKey {
+			current = gin.H{"milestones": []gin.H{}}
+			if baby.BirthDate != nil {
+				current["ageMonths"] = key
+			} else {
+				current["month"] = key
+			}
+			currentKey = key
+			timeline = append(timeline, current)
+		}
+		current["milestones"] = append(current["milestones"].([]gin.H), entry)
+	}
+	return timeline
+}
+
+// monthsBetween returns the number of whole calendar months from birth to t.
+func monthsBetween(birth, t time.Time) int {
+	months := (t.Year()-birth.Year())*12 + int(t.Month()-birth.Month())
+	if t.Day() < birth.Day() {
+		months--
+	}
+	return months
+}
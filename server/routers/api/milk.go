@@ -0,0 +1,474 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Storage times for expressed breast milk, following the CDC guidelines.
+const (
+	roomStorageTime       = 4 * time.Hour
+	thawedRoomStorageTime = 2 * time.Hour
+	fridgeStorageTime     = 4 * 24 * time.Hour
+	thawedFridgeTime      = 24 * time.Hour
+	freezerStorageMonths  = 6
+)
+
+// milkExpiry returns when milk stored at location since storedAt must be used.
+func milkExpiry(location string, expressedAt, storedAt time.Time, thawed bool) time.Time {
+	switch location {
+	case models.StorageRoom:
+		if thawed {
+			return storedAt.Add(thawedRoomStorageTime)
+		}
+		return expressedAt.Add(roomStorageTime)
+	case models.StorageFridge:
+		if thawed {
+			return storedAt.Add(thawedFridgeTime)
+		}
+		return expressedAt.Add(fridgeStorageTime)
+	default:
+		return expressedAt.AddDate(0, freezerStorageMonths, 0)
+	}
+}
+
+func validStorageLocation(location string) bool {
+	return location == models.StorageRoom || location == models.StorageFridge || location == models.StorageFreezer
+}
+
+// addToStash stores the milk of a pumping session.
+func addToStash(tx *gorm.DB, nursing models.Nursing, location string) (models.MilkStash, error) {
+	expressedAt := nursing.Time
+	if nursing.End != nil {
+		expressedAt = *nursing.End
+	}
+
+	stash := models.MilkStash{
+		ID:          uuid.NewString(),
+		BabyID:      nursing.BabyID,
+		NursingID:   &nursing.ID,
+		VolumeML:    *nursing.VolumeML,
+		RemainingML: *nursing.VolumeML,
+		Location:    location,
+		ExpressedAt: expressedAt,
+		StoredAt:    expressedAt,
+		ExpiresAt:   milkExpiry(location, expressedAt, expressedAt, false),
+	}
+	return stash, tx.Create(&stash).Error
+}
+
+// consumeFromStash draws the volume of a bottle feed from the stash, oldest
+// milk first. Frozen milk is only used once nothing in the fridge or at room
+// temperature is left, since it has to be thawed. It returns what was used
+// and the part of the feed the stash couldn't cover.
+func consumeFromStash(tx *gorm.DB, nursing models.Nursing) ([]models.MilkConsumption, float64, error) {
+	var entries []models.MilkStash
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("baby_id = ? AND remaining_ml > 0 AND discarded_at IS NULL AND expires_at > ?", nursing.BabyID, nursing.Time).
+		Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	consumptions, missing := drawFromStash(entries, *nursing.VolumeML)
+	for i := range consumptions {
+		consumption := &consumptions[i]
+		if err := tx.Model(&models.MilkStash{}).Where("id = ?", consumption.StashID).
+			Update("remaining_ml", gorm.Expr("remaining_ml - ?", consumption.VolumeML)).Error; err != nil {
+			return nil, 0, err
+		}
+
+		consumption.ID = uuid.NewString()
+		consumption.BabyID = nursing.BabyID
+		consumption.NursingID = nursing.ID
+		if err := tx.Create(consumption).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return consumptions, missing, nil
+}
+
+// drawFromStash picks the milk a feed of needed ml uses among the entries
+// still in the stash: the oldest first, frozen milk last. It returns the
+// stash and volume of each draw and the part of the feed left uncovered.
+func drawFromStash(entries []models.MilkStash, needed float64) ([]models.MilkConsumption, float64) {
+	ordered := append([]models.MilkStash(nil), entries...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		iFrozen, jFrozen := ordered[i].Location == models.StorageFreezer, ordered[j].Location == models.StorageFreezer
+		if iFrozen != jFrozen {
+			return jFrozen
+		}
+		return ordered[i].ExpressedAt.Before(ordered[j].ExpressedAt)
+	})
+
+	var consumptions []models.MilkConsumption
+	for _, entry := range ordered {
+		if needed <= 0 {
+			break
+		}
+		used := math.Min(needed, entry.RemainingML)
+		consumptions = append(consumptions, models.MilkConsumption{StashID: entry.ID, VolumeML: used})
+		needed -= used
+	}
+	return consumptions, math.Max(needed, 0)
+}
+
+// stashNursing applies a finished nursing to the milk stash: a measured
+// pumping session with a storage location is stored, and a bottle of breast
+// milk is drawn from the stash unless fromStash is false. It returns what
+// changed for the API response, or nil when the stash wasn't involved.
+func stashNursing(tx *gorm.DB, nursing models.Nursing, storage string, fromStash bool) (gin.H, error) {
+	if nursing.VolumeML == nil || *nursing.VolumeML <= 0 {
+		return nil, nil
+	}
+
+	switch {
+	case nursing.Kind == models.FeedingPumping && storage != "":
+		// A resumed session may have stored milk that was used since
+		var existing int64
+		if err := tx.Model(&models.MilkStash{}).Where("nursing_id = ?", nursing.ID).Count(&existing).Error; err != nil {
+			return nil, err
+		}
+		if existing > 0 {
+			return nil, nil
+		}
+		stash, err := addToStash(tx, nursing, storage)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"stash": stash}, nil
+	case nursing.Kind == models.FeedingBottleBreastMilk && fromStash:
+		consumptions, unstashed, err := consumeFromStash(tx, nursing)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"consumed": consumptions, "unstashedMl": unstashed}, nil
+	}
+	return nil, nil
+}
+
+// validateStorage checks the storage location given for a nursing.
+func validateStorage(kind, storage string) error {
+	if storage == "" {
+		return nil
+	}
+	if kind != models.FeedingPumping {
+		return errors.New("Only pumped milk can be stored")
+	}
+	if !validStorageLocation(storage) {
+		return errors.New("Storage must be room, fridge or freezer")
+	}
+	return nil
+}
+
+// releaseNursingMilk undoes the stash changes of a nursing that is being
+// deleted: milk a feed used goes back, and milk a pumping session added is
+// removed unless some of it was already used.
+func releaseNursingMilk(tx *gorm.DB, nursingID string) error {
+	var consumptions []models.MilkConsumption
+	if err := tx.Where("nursing_id = ?", nursingID).Find(&consumptions).Error; err != nil {
+		return err
+	}
+	for _, consumption := range consumptions {
+		if err := tx.Model(&models.MilkStash{}).Where("id = ?", consumption.StashID).
+			Update("remaining_ml", gorm.Expr("remaining_ml + ?", consumption.VolumeML)).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("nursing_id = ?", nursingID).Delete(&models.MilkConsumption{}).Error; err != nil {
+		return err
+	}
+
+	return deleteRecords(tx, &models.MilkStash{}, "nursing_id = ? AND remaining_ml = volume_ml", nursingID)
+}
+
+// moveStash moves milk to location at movedAt, shortening its expiry as
+// needed.
+func moveStash(stash *models.MilkStash, location string, movedAt time.Time) error {
+	if movedAt.After(stash.ExpiresAt) {
+		return errors.New("Milk has already expired")
+	}
+	if location == models.StorageFreezer && stash.Thawed {
+		return errors.New("Thawed milk can't be frozen again")
+	}
+
+	if stash.Location == models.StorageFreezer && location != models.StorageFreezer {
+		stash.Thawed = true
+	}
+	stash.Location = location
+	stash.StoredAt = movedAt.UTC()
+	// Moving milk never buys it more time than it had left
+	if expiry := milkExpiry(stash.Location, stash.ExpressedAt, stash.StoredAt, stash.Thawed); expiry.Before(stash.ExpiresAt) || stash.Location == models.StorageFreezer {
+		stash.ExpiresAt = expiry
+	}
+	return nil
+}
+
+// errStashEdit is returned when an edit of a nursing contradicts what already
+// happened to its milk.
+var errStashEdit = errors.New("invalid milk stash change")
+
+// restashNursing reapplies an edited nursing to the milk stash. Milk a
+// pumping session stored keeps its entry, resized to the new volume and moved
+// if the storage changed, since some of it may already have been fed.
+// Otherwise the changes of the previous version are undone and the new one is
+// applied like a new feed. A nil fromStash keeps whether the bottle was drawn
+// from the stash.
+func restashNursing(tx *gorm.DB, previous, nursing models.Nursing, storage string, fromStash *bool) (gin.H, error) {
+	var stashed []models.MilkStash
+	if err := tx.Where("nursing_id = ?", previous.ID).Limit(1).Find(&stashed).Error; err != nil {
+		return nil, err
+	}
+	if len(stashed) > 0 {
+		entry := stashed[0]
+		used := entry.VolumeML - entry.RemainingML
+		stillStored := !nursing.InProgress && nursing.Kind == models.FeedingPumping && nursing.VolumeML != nil && *nursing.VolumeML > 0
+		if stillStored {
+			if *nursing.VolumeML < used {
+				return nil, fmt.Errorf("%w: %.0f ml of this milk was already fed, the volume can't be lower", errStashEdit, used)
+			}
+			entry.VolumeML, entry.RemainingML = *nursing.VolumeML, *nursing.VolumeML-used
+			if storage != "" && storage != entry.Location {
+				if err := moveStash(&entry, storage, time.Now()); err != nil {
+					return nil, fmt.Errorf("%w: %v", errStashEdit, err)
+				}
+			}
+			if err := tx.Save(&entry).Error; err != nil {
+				return nil, err
+			}
+			return gin.H{"stash": entry}, nil
+		}
+		if used > 0 {
+			return nil, fmt.Errorf("%w: %.0f ml of this milk was already fed, it can't be removed from the stash", errStashEdit, used)
+		}
+	}
+
+	drawFromStash := true
+	if fromStash != nil {
+		drawFromStash = *fromStash
+	} else if previous.Kind == models.FeedingBottleBreastMilk {
+		var used int64
+		if err := tx.Model(&models.MilkConsumption{}).Where("nursing_id = ?", previous.ID).Count(&used).Error; err != nil {
+			return nil, err
+		}
+		drawFromStash = used > 0
+	}
+
+	if err := releaseNursingMilk(tx, previous.ID); err != nil {
+		return nil, err
+	}
+	if nursing.InProgress {
+		return nil, nil
+	}
+	return stashNursing(tx, nursing, storage, drawFromStash)
+}
+
+func SetupMilkRoutes(api *gin.RouterGroup) {
+	milk := api.Group("/milk")
+	milk.Use(AuthMiddleware()) // Add authentication middleware
+	{
+		// GET /api/milk?babyId= - List milk still in the stash
+		milk.GET("", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			var entries []models.MilkStash
+			if err := database.DB.Where("baby_id = ? AND remaining_ml > 0 AND discarded_at IS NULL", babyID).
+				Order("expressed_at").Find(&entries).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			totals := gin.H{models.StorageRoom: 0.0, models.StorageFridge: 0.0, models.StorageFreezer: 0.0}
+			for _, entry := range entries {
+				totals[entry.Location] = totals[entry.Location].(float64) + entry.RemainingML
+			}
+
+			c.JSON(http.StatusOK, gin.H{"entries": entries, "totalsMl": totals})
+		})
+
+		// GET /api/milk/expiring?babyId=&withinHours=24 - Milk to use first
+		milk.GET("/expiring", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			withinHours := 24
+			if value := c.Query("withinHours"); value != "" {
+				var err error
+				withinHours, err = strconv.Atoi(value)
+				if err != nil || withinHours < 0 {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "withinHours must be a positive number"})
+					return
+				}
+			}
+
+			now := time.Now()
+			var entries []models.MilkStash
+			if err := database.DB.Where("baby_id = ? AND remaining_ml > 0 AND discarded_at IS NULL AND expires_at <= ?",
+				babyID, now.Add(time.Duration(withinHours)*time.Hour)).
+				Order("expires_at").Find(&entries).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			response := make([]gin.H, len(entries))
+			for i, entry := range entries {
+				response[i] = gin.H{
+					"entry":          entry,
+					"expired":        !entry.ExpiresAt.After(now),
+					"hoursRemaining": math.Max(entry.ExpiresAt.Sub(now).Hours(), 0),
+				}
+			}
+			c.JSON(http.StatusOK, response)
+		})
+
+		// POST /api/milk - Add milk that didn't come from a logged pumping session
+		milk.POST("", checkBabyAccess(), func(c *gin.Context) {
+			var stashInput struct {
+				BabyID      string  `json:"babyId"`
+				Volume      float64 `json:"volume"`
+				Unit        string  `json:"unit"`
+				Location    string  `json:"location"`
+				ExpressedAt string  `json:"expressedAt"`
+				Note        string  `json:"note"`
+			}
+			if err := c.ShouldBindJSON(&stashInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			expressedAt, err := time.Parse(time.RFC3339, stashInput.ExpressedAt)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expressedAt format"})
+				return
+			}
+
+			if !validStorageLocation(stashInput.Location) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Location must be room, fridge or freezer"})
+				return
+			}
+
+			// Reuse the nursing volume conversion for ml/oz input
+			converted := models.Nursing{Kind: models.FeedingPumping}
+			if err := applyFeedingVolume(&converted, &stashInput.Volume, stashInput.Unit); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if *converted.VolumeML <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Volume must be positive"})
+				return
+			}
+
+			expressedAt = expressedAt.UTC()
+			stash := models.MilkStash{
+				ID:          uuid.NewString(),
+				BabyID:      stashInput.BabyID,
+				VolumeML:    *converted.VolumeML,
+				RemainingML: *converted.VolumeML,
+				Location:    stashInput.Location,
+				ExpressedAt: expressedAt,
+				StoredAt:    expressedAt,
+				ExpiresAt:   milkExpiry(stashInput.Location, expressedAt, expressedAt, false),
+				Note:        stashInput.Note,
+			}
+			if err := database.DB.Create(&stash).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, stash)
+		})
+
+		// POST /api/milk/:id/move - Move milk to another storage location
+		milk.POST("/:id/move", checkRecordAccess("milk_stashes", "Milk"), func(c *gin.Context) {
+			var moveInput struct {
+				Location string `json:"location"`
+				Time     string `json:"time"`
+			}
+			if err := c.ShouldBindJSON(&moveInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			var stash models.MilkStash
+			if err := database.DB.First(&stash, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Milk not found"})
+				return
+			}
+
+			if !validStorageLocation(moveInput.Location) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Location must be room, fridge or freezer"})
+				return
+			}
+
+			movedAt := time.Now()
+			if moveInput.Time != "" {
+				var err error
+				movedAt, err = time.Parse(time.RFC3339, moveInput.Time)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time format"})
+					return
+				}
+			}
+
+			if err := moveStash(&stash, moveInput.Location, movedAt); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if err := database.DB.Save(&stash).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, stash)
+		})
+
+		// POST /api/milk/:id/discard - Throw away what is left of an entry
+		milk.POST("/:id/discard", checkRecordAccess("milk_stashes", "Milk"), func(c *gin.Context) {
+			var stash models.MilkStash
+			if err := database.DB.First(&stash, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Milk not found"})
+				return
+			}
+
+			now := time.Now().UTC()
+			stash.DiscardedAt = &now
+			if err := database.DB.Save(&stash).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, stash)
+		})
+
+		milk.DELETE("/:id", checkRecordAccess("milk_stashes", "Milk"), func(c *gin.Context) {
+			var stash models.MilkStash
+			if err := database.DB.First(&stash, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Milk not found"})
+				return
+			}
+
+			if stash.RemainingML != stash.VolumeML {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%.0f ml of this milk was already fed, discard it instead", stash.VolumeML-stash.RemainingML)})
+				return
+			}
+
+			if err := deleteRecords(database.DB, &models.MilkStash{}, "id = ?", stash.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"baby-tracker/models"
+	"testing"
+	"time"
+)
+
+func TestMilkExpiry(t *testing.T) {
+	expressed := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	stored := expressed.Add(48 * time.Hour)
+
+	tests := []struct {
+		name     string
+		location string
+		thawed   bool
+		want     time.Time
+	}{
+		{"room", models.StorageRoom, false, expressed.Add(4 * time.Hour)},
+		{"fridge", models.StorageFridge, false, expressed.Add(4 * 24 * time.Hour)},
+		{"freezer", models.StorageFreezer, false, time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)},
+		{"thawed in the fridge", models.StorageFridge, true, stored.Add(24 * time.Hour)},
+		{"thawed at room temperature", models.StorageRoom, true, stored.Add(2 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := milkExpiry(tt.location, expressed, stored, tt.thawed); !got.Equal(tt.want) {
+				t.Errorf("milkExpiry = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveStash(t *testing.T) {
+	expressed := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	frozen := models.MilkStash{
+		Location:    models.StorageFreezer,
+		ExpressedAt: expressed,
+		StoredAt:    expressed,
+		ExpiresAt:   milkExpiry(models.StorageFreezer, expressed, expressed, false),
+	}
+	fridge := models.MilkStash{
+		Location:    models.StorageFridge,
+		ExpressedAt: expressed,
+		StoredAt:    expressed,
+		ExpiresAt:   milkExpiry(models.StorageFridge, expressed, expressed, false),
+	}
+
+	tests := []struct {
+		name       string
+		stash      models.MilkStash
+		location   string
+		movedAt    time.Time
+		wantExpiry time.Time
+		wantThawed bool
+		wantErr    bool
+	}{
+		{"thawed in the fridge", frozen, models.StorageFridge, expressed.AddDate(0, 1, 0), expressed.AddDate(0, 1, 1), true, false},
+		{"fridge to room keeps the earlier expiry", fridge, models.StorageRoom, expressed.Add(time.Hour), expressed.Add(4 * time.Hour), false, false},
+		{"fridge to freezer", fridge, models.StorageFreezer, expressed.Add(time.Hour), expressed.AddDate(0, 6, 0), false, false},
+		{"expired", fridge, models.StorageFreezer, expressed.Add(5 * 24 * time.Hour), time.Time{}, false, true},
+		{"thawed milk refrozen", models.MilkStash{Location: models.StorageFridge, Thawed: true, ExpiresAt: expressed.AddDate(0, 1, 0)}, models.StorageFreezer, expressed, time.Time{}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stash := tt.stash
+			err := moveStash(&stash, tt.location, tt.movedAt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if stash.Location != tt.location {
+				t.Errorf("Location = %q, want %q", stash.Location, tt.location)
+			}
+			if !stash.ExpiresAt.Equal(tt.wantExpiry) {
+				t.Errorf("ExpiresAt = %v, want %v", stash.ExpiresAt, tt.wantExpiry)
+			}
+			if stash.Thawed != tt.wantThawed {
+				t.Errorf("Thawed = %v, want %v", stash.Thawed, tt.wantThawed)
+			}
+		})
+	}
+}
+
+func TestDrawFromStash(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	entries := []models.MilkStash{
+		{ID: "frozen-old", Location: models.StorageFreezer, ExpressedAt: day.AddDate(0, -1, 0), RemainingML: 100},
+		{ID: "fridge-new", Location: models.StorageFridge, ExpressedAt: day.Add(10 * time.Hour), RemainingML: 60},
+		{ID: "room", Location: models.StorageRoom, ExpressedAt: day.Add(12 * time.Hour), RemainingML: 30},
+		{ID: "fridge-old", Location: models.StorageFridge, ExpressedAt: day.Add(2 * time.Hour), RemainingML: 50},
+	}
+
+	type draw struct {
+		stash string
+		ml    float64
+	}
+	tests := []struct {
+		name        string
+		needed      float64
+		want        []draw
+		wantMissing float64
+	}{
+		{"oldest first", 40, []draw{{"fridge-old", 40}}, 0},
+		{"spills over to the next oldest", 80, []draw{{"fridge-old", 50}, {"fridge-new", 30}}, 0},
+		{"frozen milk last", 170, []draw{{"fridge-old", 50}, {"fridge-new", 60}, {"room", 30}, {"frozen-old", 30}}, 0},
+		{"stash runs out", 300, []draw{{"fridge-old", 50}, {"fridge-new", 60}, {"room", 30}, {"frozen-old", 100}}, 60},
+		{"nothing needed", 0, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consumptions, missing := drawFromStash(entries, tt.needed)
+			var got []draw
+			for _, consumption := range consumptions {
+				got = append(got, draw{consumption.StashID, consumption.VolumeML})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("draws = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("draws = %v, want %v", got, tt.want)
+					break
+				}
+			}
+			if missing != tt.wantMissing {
+				t.Errorf("missing = %g, want %g", missing, tt.wantMissing)
+			}
+		})
+	}
+	if entries[0].ID != "frozen-old" {
+		t.Error("drawFromStash reordered the entries it was given")
+	}
+}
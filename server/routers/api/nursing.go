@@ -3,11 +3,16 @@ package api
 import (
 	"baby-tracker/database"
 	"baby-tracker/models"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 func SetupNursingRoutes(api *gin.RouterGroup) {
@@ -18,11 +23,19 @@ func SetupNursingRoutes(api *gin.RouterGroup) {
 	{
 		nursing.POST("", checkBabyAccess(), func(c *gin.Context) {
 			var nursingInput struct {
-				Type   string `json:"type"`
-				Amount string `json:"amount"`
-				Time   string `json:"time"`
-				BabyID string `json:"babyId"`
-				Note   string `json:"note"`
+				ID     string   `json:"id"` // client generated, makes retries safe
+				Kind   string   `json:"kind"`
+				Type   string   `json:"type"`
+				Amount string   `json:"amount"`
+				Volume *float64 `json:"volume"`
+				Unit   string   `json:"unit"`
+				Time   string   `json:"time"`
+				BabyID string   `json:"babyId"`
+				Note   string   `json:"note"`
+				// Storage puts pumped milk in the stash; FromStash=false
+				// records a breast milk bottle without drawing from it
+				Storage   string `json:"storage"`
+				FromStash *bool  `json:"fromStash"`
 			}
 			if err := c.ShouldBindJSON(&nursingInput); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -36,104 +49,599 @@ func SetupNursingRoutes(api *gin.RouterGroup) {
 				return
 			}
 
+			id, err := recordID(c, nursingInput.ID, "nursing")
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// The stash was only updated together with the original feed, so
+			// a replay doesn't touch it again
+			var existing models.Nursing
+			if replayCreate(c, &existing, id, nursingInput.BabyID, func() gin.H { return formatNursing(existing) }) {
+				return
+			}
+
 			nursing := models.Nursing{
-				ID:     uuid.NewString(),
+				ID:     id,
+				Kind:   nursingInput.Kind,
 				Type:   nursingInput.Type,
 				Amount: nursingInput.Amount,
 				Time:   nursingTime.UTC(),
 				BabyID: nursingInput.BabyID,
 				Note:   nursingInput.Note,
 			}
+			if err := applyFeedingVolume(&nursing, nursingInput.Volume, nursingInput.Unit); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := validateStorage(nursing.Kind, nursingInput.Storage); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 
-			if err := database.DB.Create(&nursing).Error; err != nil {
+			var milk gin.H
+			if err := database.DB.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Create(&nursing).Error; err != nil {
+					return err
+				}
+				var err error
+				milk, err = stashNursing(tx, nursing, nursingInput.Storage, nursingInput.FromStash == nil || *nursingInput.FromStash)
+				return err
+			}); err != nil {
+				// A concurrent attempt of the same request may have inserted it
+				if replayCreate(c, &existing, id, nursing.BabyID, func() gin.H { return formatNursing(existing) }) {
+					return
+				}
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 
-			c.JSON(http.StatusOK, gin.H{
-				"id":     nursing.ID,
-				"type":   nursing.Type,
-				"amount": nursing.Amount,
-				"time":   nursing.Time.Format(time.RFC3339),
-				"babyId": nursing.BabyID,
-				"note":   nursing.Note,
-			})
+			response := formatNursing(nursing)
+			if milk != nil {
+				response["milk"] = milk
+			}
+			c.JSON(http.StatusOK, response)
 		})
 
-		nursing.GET("", func(c *gin.Context) {
-			// Get user from context
-			userInterface, _ := c.Get("user")
-			user := userInterface.(models.User)
-
+		nursing.GET("", checkBabyAccess(), func(c *gin.Context) {
 			// Get babyId from query parameter
 			babyID := c.Query("babyId")
-			if babyID == "" {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Baby ID not provided"})
+
+			var nursings []models.Nursing
+			if err := database.DB.Where("baby_id = ?", babyID).Find(&nursings).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 
-			// Check if user has access to this baby
-			if err := database.DB.Preload("Babies").First(&user, "id = ?", user.ID).Error; err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			// Convert times to RFC3339 format
+			response := make([]gin.H, len(nursings))
+			for i, nursing := range nursings {
+				response[i] = formatNursing(nursing)
+			}
+			c.JSON(http.StatusOK, response)
+		})
+
+		// Timer endpoints: a nursing session is persisted while it runs so that
+		// any parent of the baby can see it and finish it.
+		nursing.POST("/start", checkBabyAccess(), func(c *gin.Context) {
+			var timerInput struct {
+				Kind   string `json:"kind"`
+				Type   string `json:"type"`
+				Start  string `json:"start"`
+				BabyID string `json:"babyId"`
+				Note   string `json:"note"`
+			}
+			if err := c.ShouldBindJSON(&timerInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
 
-			hasAccess := false
-			for _, baby := range user.Babies {
-				if baby.ID == babyID {
-					hasAccess = true
-					break
+			start := time.Now()
+			if timerInput.Start != "" {
+				var err error
+				start, err = time.Parse(time.RFC3339, timerInput.Start)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start time format"})
+					return
 				}
 			}
 
-			if !hasAccess {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this baby"})
+			if runningNursingConflict(c, timerInput.BabyID) {
 				return
 			}
 
-			var nursings []models.Nursing
-			if err := database.DB.Where("baby_id = ?", babyID).Find(&nursings).Error; err != nil {
+			nursing := models.Nursing{
+				ID:         uuid.NewString(),
+				Kind:       timerInput.Kind,
+				Type:       timerInput.Type,
+				Time:       start.UTC(),
+				InProgress: true,
+				BabyID:     timerInput.BabyID,
+				Note:       timerInput.Note,
+			}
+			if err := applyFeedingVolume(&nursing, nil, ""); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := database.DB.Create(&nursing).Error; err != nil {
+				// Another parent started one since the check
+				if errors.Is(err, gorm.ErrDuplicatedKey) && runningNursingConflict(c, nursing.BabyID) {
+					return
+				}
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 
-			// Convert times to RFC3339 format
-			response := make([]gin.H, len(nursings))
-			for i, nursing := range nursings {
-				response[i] = gin.H{
-					"id":     nursing.ID,
-					"type":   nursing.Type,
-					"amount": nursing.Amount,
-					"time":   nursing.Time.Format(time.RFC3339),
-					"babyId": nursing.BabyID,
-					"note":   nursing.Note,
+			c.JSON(http.StatusOK, formatNursing(nursing))
+		})
+
+		nursing.GET("/active", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			var running models.Nursing
+			if err := database.DB.Where("baby_id = ? AND in_progress = ?", babyID, true).First(&running).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "No nursing session in progress"})
+				return
+			}
+
+			c.JSON(http.StatusOK, formatNursing(running))
+		})
+
+		nursing.POST("/:id/stop", checkRecordAccess("nursings", "Nursing"), func(c *gin.Context) {
+			var stopInput struct {
+				End    string   `json:"end"`
+				Type   string   `json:"type"`
+				Amount string   `json:"amount"`
+				Volume *float64 `json:"volume"`
+				Unit   string   `json:"unit"`
+				Note   string   `json:"note"`
+				// Same as when logging a finished nursing
+				Storage   string `json:"storage"`
+				FromStash *bool  `json:"fromStash"`
+			}
+			if err := bindOptionalJSON(c, &stopInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			var nursing models.Nursing
+			if err := database.DB.First(&nursing, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Nursing not found"})
+				return
+			}
+
+			if !nursing.InProgress {
+				c.JSON(http.StatusConflict, gin.H{"error": "Nursing session is not in progress"})
+				return
+			}
+
+			end := time.Now()
+			if stopInput.End != "" {
+				var err error
+				end, err = time.Parse(time.RFC3339, stopInput.End)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end time format"})
+					return
 				}
 			}
+			if end.Before(nursing.Time) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "End time must be after start time"})
+				return
+			}
+
+			endUTC := end.UTC()
+			nursing.End = &endUTC
+			nursing.InProgress = false
+			// The side is often only known once the feed is over
+			if stopInput.Type != "" {
+				nursing.Type = stopInput.Type
+			}
+			if stopInput.Amount != "" {
+				nursing.Amount = stopInput.Amount
+			}
+			if stopInput.Note != "" {
+				nursing.Note = stopInput.Note
+			}
+			// Bottle and pumping volumes are usually measured at the end
+			if err := applyFeedingVolume(&nursing, stopInput.Volume, stopInput.Unit); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := validateStorage(nursing.Kind, stopInput.Storage); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			var milk gin.H
+			nursing.Version++
+			err := database.DB.Transaction(func(tx *gorm.DB) error {
+				if err := saveVersion(tx, &nursing, nursing.Version-1); err != nil {
+					return err
+				}
+				var err error
+				milk, err = stashNursing(tx, nursing, stopInput.Storage, stopInput.FromStash == nil || *stopInput.FromStash)
+				return err
+			})
+			if errors.Is(err, errVersionConflict) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Nursing was changed by someone else, reload it"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			response := formatNursing(nursing)
+			if milk != nil {
+				response["milk"] = milk
+			}
 			c.JSON(http.StatusOK, response)
 		})
 
-		nursing.DELETE("/:id", checkBabyAccess(), func(c *gin.Context) {
-			id := c.Param("id")
-			if err := database.DB.Delete(&models.Nursing{}, "id = ?", id).Error; err != nil {
+		nursing.POST("/:id/resume", checkRecordAccess("nursings", "Nursing"), func(c *gin.Context) {
+			var nursing models.Nursing
+			if err := database.DB.First(&nursing, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Nursing not found"})
+				return
+			}
+
+			// Only timer sessions have an end time that can be reopened
+			if nursing.InProgress || nursing.End == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "Nursing session cannot be resumed"})
+				return
+			}
+
+			if runningNursingConflict(c, nursing.BabyID) {
+				return
+			}
+
+			nursing.End = nil
+			nursing.InProgress = true
+			nursing.Version++
+			// Stopping again applies the final volume to the stash
+			err := database.DB.Transaction(func(tx *gorm.DB) error {
+				if err := saveVersion(tx, &nursing, nursing.Version-1); err != nil {
+					return err
+				}
+				return releaseNursingMilk(tx, nursing.ID)
+			})
+			if errors.Is(err, errVersionConflict) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Nursing was changed by someone else, reload it"})
+				return
+			}
+			if errors.Is(err, gorm.ErrDuplicatedKey) && runningNursingConflict(c, nursing.BabyID) {
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, formatNursing(nursing))
+		})
+
+		nursing.POST("/:id/cancel", checkRecordAccess("nursings", "Nursing"), func(c *gin.Context) {
+			var nursing models.Nursing
+			if err := database.DB.First(&nursing, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Nursing not found"})
+				return
+			}
+
+			if !nursing.InProgress {
+				c.JSON(http.StatusConflict, gin.H{"error": "Only a nursing session in progress can be cancelled"})
+				return
+			}
+
+			if err := deleteRecords(database.DB, &models.Nursing{}, "id = ?", nursing.ID); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 			c.JSON(http.StatusOK, gin.H{"success": true})
 		})
 
-		nursing.PUT("/:id", checkBabyAccess(), func(c *gin.Context) {
+		nursing.DELETE("/:id", checkRecordAccess("nursings", "Nursing"), func(c *gin.Context) {
 			id := c.Param("id")
+			var existing models.Nursing
+			if err := database.DB.First(&existing, "id = ?", id).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Nursing not found"})
+				return
+			}
+
+			// The nursing goes to the trash, its attachments stay until it is
+			// purged. Milk a feed drew from the stash goes back into it now.
+			if err := database.DB.Transaction(func(tx *gorm.DB) error {
+				if err := releaseNursingMilk(tx, id); err != nil {
+					return err
+				}
+				return deleteRecords(tx, &models.Nursing{}, "id = ?", id)
+			}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+
+		// Restore takes a deleted nursing out of the trash, e.g. to undo a
+		// delete
+		nursing.POST("/:id/restore", checkRecordAccess("nursings", "Nursing"), func(c *gin.Context) {
+			var restoreInput struct {
+				// The stash changes were undone by the delete, these apply
+				// them again like when logging the nursing
+				Storage   string `json:"storage"`
+				FromStash *bool  `json:"fromStash"`
+			}
+			if err := bindOptionalJSON(c, &restoreInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
 			var nursing models.Nursing
-			if err := c.ShouldBindJSON(&nursing); err != nil {
+			if !loadTrashed(c, &nursing, "Nursing") {
+				return
+			}
+
+			if !checkRestorable(c, nursing.DeletedAt) {
+				return
+			}
+
+			if err := validateStorage(nursing.Kind, restoreInput.Storage); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
-			nursing.ID = id
-			if err := database.DB.Save(&nursing).Error; err != nil {
+
+			// A cancelled timer only comes back if no other session is running
+			if nursing.InProgress && runningNursingConflict(c, nursing.BabyID) {
+				return
+			}
+
+			var milk gin.H
+			if err := database.DB.Transaction(func(tx *gorm.DB) error {
+				if err := restoreRecord(tx, "nursings", &nursing, nursing.ID); err != nil {
+					return err
+				}
+				if nursing.InProgress {
+					return nil
+				}
+				var err error
+				milk, err = stashNursing(tx, nursing, restoreInput.Storage, restoreInput.FromStash == nil || *restoreInput.FromStash)
+				return err
+			}); err != nil {
+				if errors.Is(err, gorm.ErrDuplicatedKey) && runningNursingConflict(c, nursing.BabyID) {
+					return
+				}
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
-			c.JSON(http.StatusOK, nursing)
+
+			response := formatNursing(nursing)
+			if milk != nil {
+				response["milk"] = milk
+			}
+			c.JSON(http.StatusOK, response)
 		})
+
+		nursing.PUT("/:id", checkRecordAccess("nursings", "Nursing"), func(c *gin.Context) {
+			id := c.Param("id")
+			var existing models.Nursing
+			if err := database.DB.First(&existing, "id = ?", id).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Nursing not found"})
+				return
+			}
+
+			version, ok := ifMatchVersion(c)
+			if !ok {
+				return
+			}
+			if version != existing.Version {
+				preconditionFailed(c, existing.Version, formatNursing(existing))
+				return
+			}
+
+			var nursingInput nursingEdit
+			nursing := existing
+			if !bindUpdate(c, &nursing, &nursingInput) {
+				return
+			}
+			nursing.Version = version + 1
+			if err := validateStorage(nursing.Kind, nursingInput.Storage); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			// The stash follows the edited volume, kind and storage
+			err := database.DB.Transaction(func(tx *gorm.DB) error {
+				if err := saveVersion(tx, &nursing, version); err != nil {
+					return err
+				}
+				_, err := restashNursing(tx, existing, nursing, nursingInput.Storage, nursingInput.FromStash)
+				return err
+			})
+			if errors.Is(err, errStashEdit) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, errVersionConflict) {
+				// Another update got in since the version was checked
+				if err := database.DB.First(&existing, "id = ?", id).Error; err != nil {
+					c.JSON(http.StatusNotFound, gin.H{"error": "Nursing not found"})
+					return
+				}
+				preconditionFailed(c, existing.Version, formatNursing(existing))
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			setETag(c, nursing.Version)
+			c.JSON(http.StatusOK, formatNursing(nursing))
+		})
+	}
+}
+
+// runningNursingConflict answers with the nursing session already running for
+// the baby, if any, when another one would be started.
+func runningNursingConflict(c *gin.Context, babyID string) bool {
+	var running models.Nursing
+	if err := database.DB.Where("baby_id = ? AND in_progress = ?", babyID, true).First(&running).Error; err != nil {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "A nursing session is already in progress", "nursing": formatNursing(running)})
+	return true
+}
+
+// validateNursingTimes checks that a session in progress has no end yet and
+// that an end time, only recorded by the timer, comes after the start.
+func validateNursingTimes(nursing models.Nursing) error {
+	if nursing.End == nil {
+		return nil
+	}
+	if nursing.InProgress {
+		return errors.New("A nursing session in progress has no end time, stop it instead")
+	}
+	if nursing.End.Before(nursing.Time) {
+		return errors.New("End time must be after start time")
+	}
+	return nil
+}
+
+// formatNursing converts a nursing to its API representation.
+func formatNursing(nursing models.Nursing) gin.H {
+	return gin.H{
+		"id":         nursing.ID,
+		"kind":       nursing.Kind,
+		"type":       nursing.Type,
+		"amount":     nursing.Amount,
+		"volumeMl":   nursing.VolumeML,
+		"unit":       nursing.Unit,
+		"time":       nursing.Time.Format(time.RFC3339),
+		"end":        formatOptionalTime(nursing.End),
+		"inProgress": nursing.InProgress,
+		"babyId":     nursing.BabyID,
+		"note":       nursing.Note,
+		"version":    nursing.Version,
+	}
+}
+
+const mlPerFluidOunce = 29.5735
+
+// applyFeedingVolume validates the feeding kind and breast side of a nursing
+// and stores the volume in millilitres, converting from fluid ounces when
+// needed. The unit the volume was entered in is kept so clients can display it
+// back.
+func applyFeedingVolume(nursing *models.Nursing, volume *float64, unit string) error {
+	switch nursing.Kind {
+	case "":
+		nursing.Kind = models.FeedingBreast
+	case models.FeedingBreast, models.FeedingBottleBreastMilk, models.FeedingBottleFormula, models.FeedingPumping:
+	default:
+		return fmt.Errorf("Invalid kind %q", nursing.Kind)
+	}
+
+	switch nursing.Type {
+	case "", models.SideLeft, models.SideRight, models.SideBoth:
+	default:
+		return fmt.Errorf("Invalid type %q, use left, right or both", nursing.Type)
+	}
+
+	if volume == nil {
+		return nil
+	}
+	if *volume < 0 {
+		return errors.New("Volume cannot be negative")
+	}
+
+	unit, err := volumeUnit(unit)
+	if err != nil {
+		return err
+	}
+	ml := *volume
+	if unit == "oz" {
+		ml = *volume * mlPerFluidOunce
+	}
+
+	ml = math.Round(ml*10) / 10
+	nursing.VolumeML = &ml
+	nursing.Unit = unit
+	return nil
+}
+
+// volumeUnit normalizes the unit a volume was entered in to "ml" or "oz".
+// Millilitres are the default.
+func volumeUnit(unit string) (string, error) {
+	switch strings.ToLower(unit) {
+	case "", "ml":
+		return "ml", nil
+	case "oz", "floz", "fl oz":
+		return "oz", nil
+	default:
+		return "", fmt.Errorf("Invalid unit %q, use ml or oz", unit)
+	}
+}
+
+// nursingEdit is the body of a nursing edit, for PUT and batch operations. It
+// is the nursing as the API returns it: the volume can be given as volume in
+// unit, like when logging a nursing, or as volumeMl in millilitres, with unit
+// only recording how it was entered.
+type nursingEdit struct {
+	Kind     string     `json:"kind"`
+	Type     string     `json:"type"`
+	Amount   string     `json:"amount"`
+	Volume   *float64   `json:"volume"`
+	VolumeML *float64   `json:"volumeMl"`
+	Unit     string     `json:"unit"`
+	Time     time.Time  `json:"time"`
+	End      *time.Time `json:"end"`
+	Note     string     `json:"note"`
+	// Storage moves edited pumped milk to another location and FromStash
+	// changes whether a bottle draws from the stash; both keep their
+	// previous value when left out
+	Storage   string `json:"storage"`
+	FromStash *bool  `json:"fromStash"`
+	// InProgress is only read when a batch creates a running session
+	InProgress bool `json:"inProgress"`
+}
+
+// apply copies the editable fields onto nursing and validates them. Identity,
+// version and the timer state are left alone.
+func (edit nursingEdit) apply(nursing *models.Nursing) error {
+	nursing.Kind = edit.Kind
+	nursing.Type = edit.Type
+	nursing.Amount = edit.Amount
+	nursing.Time = edit.Time.UTC()
+	nursing.End = nil
+	if edit.End != nil {
+		end := edit.End.UTC()
+		nursing.End = &end
+	}
+	nursing.Note = edit.Note
+	if err := validateNursingTimes(*nursing); err != nil {
+		return err
+	}
+
+	nursing.VolumeML, nursing.Unit = nil, ""
+	if edit.Volume != nil || edit.VolumeML == nil {
+		return applyFeedingVolume(nursing, edit.Volume, edit.Unit)
+	}
+	unit, err := volumeUnit(edit.Unit)
+	if err != nil {
+		return err
+	}
+	if err := applyFeedingVolume(nursing, edit.VolumeML, "ml"); err != nil {
+		return err
+	}
+	nursing.Unit = unit
+	return nil
+}
+
+// intakeML returns the measured volume the baby drank during a feed. Breast
+// feeds have no measurable volume and pumping isn't intake.
+func intakeML(nursing models.Nursing) float64 {
+	if nursing.VolumeML == nil {
+		return 0
+	}
+	if nursing.Kind == models.FeedingBottleBreastMilk || nursing.Kind == models.FeedingBottleFormula {
+		return *nursing.VolumeML
 	}
+	return 0
 }
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"baby-tracker/models"
+	"testing"
+	"time"
+)
+
+func float64Ptr(v float64) *float64 { return &v }
+
+func TestApplyFeedingVolume(t *testing.T) {
+	tests := []struct {
+		name     string
+		nursing  models.Nursing
+		volume   *float64
+		unit     string
+		wantML   *float64
+		wantUnit string
+		wantErr  bool
+	}{
+		{"breast without volume", models.Nursing{Type: models.SideLeft}, nil, "", nil, "", false},
+		{"kind defaults to breast", models.Nursing{}, nil, "", nil, "", false},
+		{"millilitres", models.Nursing{Kind: models.FeedingBottleFormula}, float64Ptr(120), "ml", float64Ptr(120), "ml", false},
+		{"unit defaults to millilitres", models.Nursing{Kind: models.FeedingBottleFormula}, float64Ptr(90), "", float64Ptr(90), "ml", false},
+		{"fluid ounces", models.Nursing{Kind: models.FeedingBottleFormula}, float64Ptr(4), "oz", float64Ptr(118.3), "oz", false},
+		{"fluid ounces spelled out", models.Nursing{Kind: models.FeedingPumping}, float64Ptr(2), "fl oz", float64Ptr(59.1), "oz", false},
+		{"negative volume", models.Nursing{Kind: models.FeedingBottleFormula}, float64Ptr(-1), "ml", nil, "", true},
+		{"unknown unit", models.Nursing{Kind: models.FeedingBottleFormula}, float64Ptr(4), "cups", nil, "", true},
+		{"unknown kind", models.Nursing{Kind: "juice"}, nil, "", nil, "", true},
+		{"unknown side", models.Nursing{Type: "middle"}, nil, "", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nursing := tt.nursing
+			err := applyFeedingVolume(&nursing, tt.volume, tt.unit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if nursing.Kind == "" {
+				t.Errorf("kind was not defaulted")
+			}
+			if (nursing.VolumeML == nil) != (tt.wantML == nil) || (tt.wantML != nil && *nursing.VolumeML != *tt.wantML) {
+				t.Errorf("VolumeML = %v, want %v", nursing.VolumeML, tt.wantML)
+			}
+			if nursing.Unit != tt.wantUnit {
+				t.Errorf("Unit = %q, want %q", nursing.Unit, tt.wantUnit)
+			}
+		})
+	}
+}
+
+func TestApplyNursingEdit(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	existing := models.Nursing{ID: "n1", BabyID: "b1", Kind: models.FeedingBottleFormula, VolumeML: float64Ptr(100), Unit: "ml", Time: start, Version: 2}
+
+	tests := []struct {
+		name     string
+		edit     nursingEdit
+		wantML   *float64
+		wantUnit string
+		wantErr  bool
+	}{
+		{"volume in ounces", nursingEdit{Kind: models.FeedingBottleFormula, Volume: float64Ptr(4), Unit: "oz", Time: start}, float64Ptr(118.3), "oz", false},
+		{"volumeMl keeps the entry unit", nursingEdit{Kind: models.FeedingBottleFormula, VolumeML: float64Ptr(118.3), Unit: "oz", Time: start}, float64Ptr(118.3), "oz", false},
+		{"volume wins over volumeMl", nursingEdit{Kind: models.FeedingBottleFormula, Volume: float64Ptr(60), VolumeML: float64Ptr(118.3), Time: start}, float64Ptr(60), "ml", false},
+		{"volume removed", nursingEdit{Kind: models.FeedingBreast, Type: models.SideBoth, Time: start}, nil, "", false},
+		{"unknown unit with volumeMl", nursingEdit{Kind: models.FeedingBottleFormula, VolumeML: float64Ptr(100), Unit: "cups", Time: start}, nil, "", true},
+		{"unknown side", nursingEdit{Kind: models.FeedingBreast, Type: "top", Time: start}, nil, "", true},
+		{"end before start", nursingEdit{Kind: models.FeedingBreast, Time: start, End: timePtr(start.Add(-time.Minute))}, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nursing := existing
+			err := tt.edit.apply(&nursing)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if nursing.ID != existing.ID || nursing.BabyID != existing.BabyID || nursing.Version != existing.Version {
+				t.Errorf("identity changed: %+v", nursing)
+			}
+			if (nursing.VolumeML == nil) != (tt.wantML == nil) || (tt.wantML != nil && *nursing.VolumeML != *tt.wantML) {
+				t.Errorf("VolumeML = %v, want %v", nursing.VolumeML, tt.wantML)
+			}
+			if nursing.Unit != tt.wantUnit {
+				t.Errorf("Unit = %q, want %q", nursing.Unit, tt.wantUnit)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time { return &t }
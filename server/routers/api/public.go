@@ -13,22 +13,25 @@ import (
 func SetupPublicRoutes(api *gin.RouterGroup) {
 	// Public baby endpoint
 	api.GET("/baby/:shareToken", func(c *gin.Context) {
-		shareToken := c.Param("shareToken")
-		var baby models.Baby
-		if err := database.DB.First(&baby, "share_token = ?", shareToken).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
+		baby, ok := loadSharedBaby(c, c.Param("shareToken"))
+		if !ok {
 			return
 		}
 
-		c.JSON(http.StatusOK, baby)
+		// The profile (birth, sex, measurements) stays with the parents
+		c.JSON(http.StatusOK, gin.H{
+			"id":              baby.ID,
+			"name":            baby.Name,
+			"timezone":        baby.Timezone,
+			"avatarUpdatedAt": baby.AvatarUpdatedAt,
+		})
 	})
 
 	// Public sleep endpoint
 	api.GET("/sleep", func(c *gin.Context) {
-		shareToken := c.Query("babyId") // Using babyId param to maintain frontend compatibility
-		var baby models.Baby
-		if err := database.DB.First(&baby, "share_token = ?", shareToken).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
+		// Using babyId param to maintain frontend compatibility
+		baby, ok := loadSharedBaby(c, c.Query("babyId"))
+		if !ok {
 			return
 		}
 
@@ -41,22 +44,16 @@ func SetupPublicRoutes(api *gin.RouterGroup) {
 		// Convert times to RFC3339 format
 		response := make([]gin.H, len(sleeps))
 		for i, sleep := range sleeps {
-			response[i] = gin.H{
-				"id":     sleep.ID,
-				"start":  sleep.Start.Format(time.RFC3339),
-				"end":    sleep.End.Format(time.RFC3339),
-				"babyId": sleep.BabyID,
-			}
+			response[i] = formatPublicSleep(sleep)
 		}
 		c.JSON(http.StatusOK, response)
 	})
 
 	// Public diaper endpoint
 	api.GET("/diaper", func(c *gin.Context) {
-		shareToken := c.Query("babyId") // Using babyId param to maintain frontend compatibility
-		var baby models.Baby
-		if err := database.DB.First(&baby, "share_token = ?", shareToken).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
+		// Using babyId param to maintain frontend compatibility
+		baby, ok := loadSharedBaby(c, c.Query("babyId"))
+		if !ok {
 			return
 		}
 
@@ -69,22 +66,16 @@ func SetupPublicRoutes(api *gin.RouterGroup) {
 		// Convert times to RFC3339 format
 		response := make([]gin.H, len(diapers))
 		for i, diaper := range diapers {
-			response[i] = gin.H{
-				"id":     diaper.ID,
-				"type":   diaper.Type,
-				"time":   diaper.Time.Format(time.RFC3339),
-				"babyId": diaper.BabyID,
-			}
+			response[i] = formatPublicDiaper(diaper)
 		}
 		c.JSON(http.StatusOK, response)
 	})
 
 	// Public nursing endpoint
 	api.GET("/nursing", func(c *gin.Context) {
-		shareToken := c.Query("babyId") // Using babyId param to maintain frontend compatibility
-		var baby models.Baby
-		if err := database.DB.First(&baby, "share_token = ?", shareToken).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
+		// Using babyId param to maintain frontend compatibility
+		baby, ok := loadSharedBaby(c, c.Query("babyId"))
+		if !ok {
 			return
 		}
 
@@ -97,23 +88,37 @@ func SetupPublicRoutes(api *gin.RouterGroup) {
 		// Convert times to RFC3339 format
 		response := make([]gin.H, len(nursings))
 		for i, nursing := range nursings {
-			response[i] = gin.H{
-				"id":     nursing.ID,
-				"type":   nursing.Type,
-				"amount": nursing.Amount,
-				"time":   nursing.Time.Format(time.RFC3339),
-				"babyId": nursing.BabyID,
-			}
+			response[i] = formatPublicNursing(nursing)
+		}
+		c.JSON(http.StatusOK, response)
+	})
+
+	// Public custom event endpoint
+	api.GET("/events", func(c *gin.Context) {
+		// Using babyId param to maintain frontend compatibility
+		baby, ok := loadSharedBaby(c, c.Query("babyId"))
+		if !ok {
+			return
+		}
+
+		var events []models.CustomEvent
+		if err := database.DB.Where("baby_id = ?", baby.ID).Order("time").Find(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		response, err := formatPublicEvents(baby.ID, events)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
 		c.JSON(http.StatusOK, response)
 	})
 
 	// Public report endpoints
 	api.GET("/report/:shareToken", func(c *gin.Context) {
-		shareToken := c.Param("shareToken")
-		var baby models.Baby
-		if err := database.DB.First(&baby, "share_token = ?", shareToken).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
+		baby, ok := loadSharedBaby(c, c.Param("shareToken"))
+		if !ok {
 			return
 		}
 
@@ -121,6 +126,7 @@ func SetupPublicRoutes(api *gin.RouterGroup) {
 		var sleeps []models.Sleep
 		var diapers []models.Diaper
 		var nursings []models.Nursing
+		var events []models.CustomEvent
 
 		if err := database.DB.Where("baby_id = ?", baby.ID).Find(&sleeps).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -134,31 +140,34 @@ func SetupPublicRoutes(api *gin.RouterGroup) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		if err := database.DB.Where("baby_id = ?", baby.ID).Order("time").Find(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		eventResponse, err := formatPublicEvents(baby.ID, events)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"sleeps":   sleeps,
-			"diapers":  diapers,
-			"nursings": nursings,
-		})
+		c.JSON(http.StatusOK, formatPublicRecords(sleeps, diapers, nursings, eventResponse))
 	})
 
 	api.GET("/report/:shareToken/weekly", func(c *gin.Context) {
-		shareToken := c.Param("shareToken")
-		var baby models.Baby
-		if err := database.DB.First(&baby, "share_token = ?", shareToken).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
+		baby, ok := loadSharedBaby(c, c.Param("shareToken"))
+		if !ok {
 			return
 		}
 
-		// Get all records for the last 7 days
-		now := time.Now().UTC()
-		weekAgo := now.AddDate(0, 0, -7)
+		// Get all records for the last 7 report days, today included
+		weekAgo, _ := babyDayBounds(baby, babyToday(baby, time.Now()).AddDate(0, 0, -6))
 
 		var sleeps []models.Sleep
 		var diapers []models.Diaper
 		var nursings []models.Nursing
+		var events []models.CustomEvent
 
-		if err := database.DB.Where("baby_id = ? AND start >= ?", baby.ID, weekAgo).Find(&sleeps).Error; err != nil {
+		if err := database.DB.Where("baby_id = ? AND (start >= ? OR in_progress)", baby.ID, weekAgo).Find(&sleeps).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -170,11 +179,123 @@ func SetupPublicRoutes(api *gin.RouterGroup) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		if err := database.DB.Where("baby_id = ? AND time >= ?", baby.ID, weekAgo).Order("time").Find(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		eventResponse, err := formatPublicEvents(baby.ID, events)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"sleeps":   sleeps,
-			"diapers":  diapers,
-			"nursings": nursings,
-		})
+		c.JSON(http.StatusOK, formatPublicRecords(sleeps, diapers, nursings, eventResponse))
+	})
+
+	// Public feeding analytics, GET /api/public/analytics/:shareToken/feeding?from=&to=
+	api.GET("/analytics/:shareToken/feeding", func(c *gin.Context) {
+		baby, ok := loadSharedBaby(c, c.Param("shareToken"))
+		if !ok {
+			return
+		}
+
+		getFeedingAnalytics(c, baby)
+	})
+
+	// Public next feed and nap prediction, GET /api/public/analytics/:shareToken/prediction
+	api.GET("/analytics/:shareToken/prediction", func(c *gin.Context) {
+		baby, ok := loadSharedBaby(c, c.Param("shareToken"))
+		if !ok {
+			return
+		}
+
+		getPredictions(c, baby)
 	})
 }
+
+// loadSharedBaby loads the baby shared with shareToken. An empty token never
+// matches: babies that aren't shared have none.
+func loadSharedBaby(c *gin.Context, shareToken string) (models.Baby, bool) {
+	var baby models.Baby
+	if shareToken == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
+		return baby, false
+	}
+	if err := database.DB.First(&baby, "share_token = ?", shareToken).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
+		return baby, false
+	}
+	return baby, true
+}
+
+// formatPublicSleep formats a sleep for share views, without its note.
+func formatPublicSleep(sleep models.Sleep) gin.H {
+	return gin.H{
+		"id":         sleep.ID,
+		"start":      sleep.Start.Format(time.RFC3339),
+		"end":        formatOptionalTime(sleep.End),
+		"inProgress": sleep.InProgress,
+		"babyId":     sleep.BabyID,
+	}
+}
+
+// formatPublicDiaper formats a diaper for share views, without its note.
+func formatPublicDiaper(diaper models.Diaper) gin.H {
+	return gin.H{
+		"id":     diaper.ID,
+		"type":   diaper.Type,
+		"time":   diaper.Time.Format(time.RFC3339),
+		"babyId": diaper.BabyID,
+	}
+}
+
+// formatPublicNursing formats a nursing for share views, without its note.
+func formatPublicNursing(nursing models.Nursing) gin.H {
+	return gin.H{
+		"id":         nursing.ID,
+		"kind":       nursing.Kind,
+		"type":       nursing.Type,
+		"amount":     nursing.Amount,
+		"volumeMl":   nursing.VolumeML,
+		"unit":       nursing.Unit,
+		"time":       nursing.Time.Format(time.RFC3339),
+		"end":        formatOptionalTime(nursing.End),
+		"inProgress": nursing.InProgress,
+		"babyId":     nursing.BabyID,
+	}
+}
+
+// formatPublicRecords builds the body of the public reports.
+func formatPublicRecords(sleeps []models.Sleep, diapers []models.Diaper, nursings []models.Nursing, events []gin.H) gin.H {
+	sleepResponse := make([]gin.H, len(sleeps))
+	for i, sleep := range sleeps {
+		sleepResponse[i] = formatPublicSleep(sleep)
+	}
+	diaperResponse := make([]gin.H, len(diapers))
+	for i, diaper := range diapers {
+		diaperResponse[i] = formatPublicDiaper(diaper)
+	}
+	nursingResponse := make([]gin.H, len(nursings))
+	for i, nursing := range nursings {
+		nursingResponse[i] = formatPublicNursing(nursing)
+	}
+	return gin.H{
+		"sleeps":       sleepResponse,
+		"diapers":      diaperResponse,
+		"nursings":     nursingResponse,
+		"customEvents": events,
+	}
+}
+
+// formatPublicEvents formats custom events for share views, leaving out
+// notes like the other public endpoints do.
+func formatPublicEvents(babyID string, events []models.CustomEvent) ([]gin.H, error) {
+	response, err := formatCustomEvents(babyID, events)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range response {
+		delete(event, "note")
+	}
+	return response, nil
+}
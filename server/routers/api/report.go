@@ -9,19 +9,24 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-type DailyReport struct {
-	Date            time.Time        `json:"date"`
-	Diapers         []models.Diaper  `json:"diapers"`
-	Nursings        []models.Nursing `json:"nursings"`
-	Sleeps          []models.Sleep   `json:"sleeps"`
-	TotalHoursSlept float64          `json:"totalHoursSlept"`
+// BabyAge is a baby's age on a report day. The corrected age, counted from
+// the due date, is only given for babies born before 37 weeks.
+type BabyAge struct {
+	Days           int  `json:"days"`
+	Weeks          int  `json:"weeks"`
+	CorrectedDays  *int `json:"correctedDays,omitempty"`
+	CorrectedWeeks *int `json:"correctedWeeks,omitempty"`
 }
 
 type DailySummary struct {
 	Date            time.Time `json:"date"`
 	TotalHoursSlept float64   `json:"totalHoursSlept"`
 	DiaperCount     int       `json:"diaperCount"`
-	NursingCount    int       `json:"nursingCount"`
+	NursingCount    int       `json:"nursingCount"` // feeds only, pumping sessions excluded
+	IntakeML        float64   `json:"intakeMl"`
+	// Average time between the starts of consecutive feeds, nil with less
+	// than two feeds
+	AvgFeedIntervalMinutes *float64 `json:"avgFeedIntervalMinutes"`
 }
 
 type WeeklyReport struct {
@@ -31,14 +36,113 @@ type WeeklyReport struct {
 	AvgSleepHours     float64        `json:"avgSleepHours"`
 	AvgDiapersPerDay  float64        `json:"avgDiapersPerDay"`
 	AvgNursingsPerDay float64        `json:"avgNursingsPerDay"`
+	AvgIntakeMLPerDay float64        `json:"avgIntakeMlPerDay"`
+	Age               *BabyAge       `json:"age"` // on the last day
 }
 
-func getDailyReport(c *gin.Context, babyID string, date time.Time) {
-	// Get start and end of the day
-	// go from 01:00 to 01:00
-	cet, _ := time.LoadLocation("Europe/Paris")
-	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 1, 0, 0, 0, cet)
-	endOfDay := startOfDay.Add(24 * time.Hour)
+type RangeReport struct {
+	StartDate   time.Time       `json:"startDate"`
+	EndDate     time.Time       `json:"endDate"`
+	Granularity string          `json:"granularity"`
+	Periods     []PeriodSummary `json:"periods"`
+}
+
+// maxRangeDays bounds the length of a range report.
+const maxRangeDays = 5 * 366
+
+// babyLocation returns the baby's configured timezone, falling back to the
+// default when it is missing or unknown.
+func babyLocation(baby models.Baby) *time.Location {
+	if loc, err := time.LoadLocation(baby.Timezone); err == nil && baby.Timezone != "" {
+		return loc
+	}
+	loc, _ := time.LoadLocation(models.DefaultTimezone)
+	return loc
+}
+
+// babyDayBounds returns the start and end of the baby's report day for the
+// calendar date of date. Both boundaries are built from the local wall clock,
+// so a day spanning a DST transition is 23 or 25 hours long.
+func babyDayBounds(baby models.Baby, date time.Time) (time.Time, time.Time) {
+	loc := babyLocation(baby)
+	start := time.Date(date.Year(), date.Month(), date.Day(), baby.DayStartHour, 0, 0, 0, loc)
+	end := time.Date(date.Year(), date.Month(), date.Day()+1, baby.DayStartHour, 0, 0, 0, loc)
+	return start, end
+}
+
+// babyToday returns the calendar date of the report day that contains now.
+// Before the day start hour, that is still the previous calendar day.
+func babyToday(baby models.Baby, now time.Time) time.Time {
+	local := now.In(babyLocation(baby))
+	if local.Hour() < baby.DayStartHour {
+		local = local.AddDate(0, 0, -1)
+	}
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Gestational ages used for the corrected age
+const (
+	fullTermDays = 280 // 40 weeks
+	pretermDays  = 259 // born before 37 weeks
+)
+
+// babyAge returns the age of the baby at t, or nil without a birth date.
+func babyAge(baby models.Baby, t time.Time) *BabyAge {
+	if baby.BirthDate == nil || t.Before(*baby.BirthDate) {
+		return nil
+	}
+
+	days := int(t.Sub(*baby.BirthDate).Hours() / 24)
+	age := &BabyAge{Days: days, Weeks: days / 7}
+	if baby.GestationalAgeDays != nil && *baby.GestationalAgeDays < pretermDays {
+		// Negative until the due date
+		corrected := days - (fullTermDays - *baby.GestationalAgeDays)
+		correctedWeeks := corrected / 7
+		age.CorrectedDays = &corrected
+		age.CorrectedWeeks = &correctedWeeks
+	}
+	return age
+}
+
+// loadBaby fetches a baby by ID, writing a 404 response when it doesn't exist.
+func loadBaby(c *gin.Context, babyID string) (models.Baby, bool) {
+	var baby models.Baby
+	if err := database.DB.First(&baby, "id = ?", babyID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Baby not found"})
+		return baby, false
+	}
+	return baby, true
+}
+
+// parseReportRange reads the report days of the from and to query
+// parameters, to defaulting to today. It writes the error response and returns
+// false when they are invalid.
+func parseReportRange(c *gin.Context, baby models.Baby) (time.Time, time.Time, bool) {
+	from, err := time.Parse("2006-01-02", c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date format. Use YYYY-MM-DD"})
+		return time.Time{}, time.Time{}, false
+	}
+
+	to := babyToday(baby, time.Now())
+	if toStr := c.Query("to"); toStr != "" {
+		to, err = time.Parse("2006-01-02", toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date format. Use YYYY-MM-DD"})
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+func getDailyReport(c *gin.Context, baby models.Baby, date time.Time) {
+	// Get start and end of the baby's report day
+	startOfDay, endOfDay := babyDayBounds(baby, date)
+	babyID := baby.ID
 
 	var diapers []models.Diaper
 	if err := database.DB.Where("baby_id = ? AND time >= ? AND time < ?",
@@ -56,7 +160,50 @@ func getDailyReport(c *gin.Context, babyID string, date time.Time) {
 
 	var sleeps []models.Sleep
 	if err := database.DB.Where("baby_id = ? AND start >= ? AND start < ?",
-		babyID, startOfDay.Add(-1*time.Hour), startOfDay.Add(23*time.Hour)).Order("start").Find(&sleeps).Error; err != nil {
+		babyID, startOfDay, endOfDay).Order("start").Find(&sleeps).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var doses []models.MedicationDose
+	if err := database.DB.Where("baby_id = ? AND time >= ? AND time < ?",
+		babyID, startOfDay, endOfDay).Order("time").Find(&doses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var medications []models.Medication
+	if err := database.DB.Where("baby_id = ?", babyID).Find(&medications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	medicationNames := make(map[string]string, len(medications))
+	for _, medication := range medications {
+		medicationNames[medication.ID] = medication.Name
+	}
+
+	var foods []models.FoodIntroduction
+	if err := database.DB.Where("baby_id = ? AND time >= ? AND time < ?",
+		babyID, startOfDay, endOfDay).Order("time").Find(&foods).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var temperatures []models.Temperature
+	if err := database.DB.Where("baby_id = ? AND time >= ? AND time < ?",
+		babyID, startOfDay, endOfDay).Order("time").Find(&temperatures).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var customEvents []models.CustomEvent
+	if err := database.DB.Where("baby_id = ? AND time >= ? AND time < ?",
+		babyID, startOfDay, endOfDay).Order("time").Find(&customEvents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	customEventResponse, err := formatCustomEvents(babyID, customEvents)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -64,128 +211,158 @@ func getDailyReport(c *gin.Context, babyID string, date time.Time) {
 	// Convert to response format with notes included
 	sleepResponse := make([]gin.H, len(sleeps))
 	for i, sleep := range sleeps {
-		sleepResponse[i] = gin.H{
-			"id":     sleep.ID,
-			"start":  sleep.Start.Format(time.RFC3339),
-			"end":    sleep.End.Format(time.RFC3339),
-			"babyId": sleep.BabyID,
-			"note":   sleep.Note,
-		}
+		sleepResponse[i] = formatSleep(sleep)
 	}
 
 	diaperResponse := make([]gin.H, len(diapers))
 	for i, diaper := range diapers {
-		diaperResponse[i] = gin.H{
-			"id":     diaper.ID,
-			"type":   diaper.Type,
-			"time":   diaper.Time.Format(time.RFC3339),
-			"babyId": diaper.BabyID,
-			"note":   diaper.Note,
-		}
+		diaperResponse[i] = formatDiaper(diaper)
 	}
 
 	nursingResponse := make([]gin.H, len(nursings))
 	for i, nursing := range nursings {
-		nursingResponse[i] = gin.H{
-			"id":     nursing.ID,
-			"type":   nursing.Type,
-			"amount": nursing.Amount,
-			"time":   nursing.Time.Format(time.RFC3339),
-			"babyId": nursing.BabyID,
-			"note":   nursing.Note,
+		nursingResponse[i] = formatNursing(nursing)
+	}
+
+	doseResponse := make([]gin.H, len(doses))
+	for i, dose := range doses {
+		doseResponse[i] = formatDose(dose, medicationNames[dose.MedicationID])
+	}
+
+	temperatureResponse := make([]gin.H, len(temperatures))
+	for i, temperature := range temperatures {
+		temperatureResponse[i] = formatTemperature(temperature, baby)
+	}
+
+	// Link each food reaction to the diapers and temperatures of the rest of
+	// the day, which the report lists
+	reactions := []gin.H{}
+	for _, food := range foods {
+		if !hasReaction(food) {
+			continue
+		}
+		diaperIDs, temperatureIDs := []string{}, []string{}
+		if err := database.DB.Model(&models.Diaper{}).Where("baby_id = ? AND time >= ? AND time < ?",
+			babyID, food.Time, endOfDay).Order("time").Pluck("id", &diaperIDs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := database.DB.Model(&models.Temperature{}).Where("baby_id = ? AND time >= ? AND time < ?",
+			babyID, food.Time, endOfDay).Order("time").Pluck("id", &temperatureIDs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
+		reactions = append(reactions, gin.H{
+			"foodId":         food.ID,
+			"diaperIds":      diaperIDs,
+			"temperatureIds": temperatureIDs,
+		})
 	}
 
-	// Calculate total hours slept
+	// Calculate total hours slept within the day, counting a running sleep
+	// up to now, like the aggregated reports do
+	var overlapping []models.Sleep
+	if err := database.DB.Where("baby_id = ? AND start < ? AND (\"end\" > ? OR in_progress)",
+		babyID, endOfDay, startOfDay).Find(&overlapping).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
 	var totalHoursSlept float64
-	for _, sleep := range sleeps {
-		totalHoursSlept += sleep.End.Sub(sleep.Start).Hours()
+	for _, sleep := range overlapping {
+		start, end := sleep.Start, sleepEnd(sleep, now)
+		if start.Before(startOfDay) {
+			start = startOfDay
+		}
+		if end.After(endOfDay) {
+			end = endOfDay
+		}
+		if end.After(start) {
+			totalHoursSlept += end.Sub(start).Hours()
+		}
+	}
+
+	// Total bottle intake and pumped volume for the day
+	var totalIntakeML, totalPumpedML float64
+	for _, nursing := range nursings {
+		totalIntakeML += intakeML(nursing)
+		if nursing.Kind == models.FeedingPumping && nursing.VolumeML != nil {
+			totalPumpedML += *nursing.VolumeML
+		}
 	}
 
 	// Send response directly
 	c.JSON(http.StatusOK, gin.H{
 		"date":            startOfDay,
+		"age":             babyAge(baby, startOfDay),
 		"diapers":         diaperResponse,
 		"nursings":        nursingResponse,
 		"sleeps":          sleepResponse,
+		"medications":     doseResponse,
+		"foods":           foods,
+		"temperatures":    temperatureResponse,
+		"reactions":       reactions,
+		"customEvents":    customEventResponse,
 		"totalHoursSlept": totalHoursSlept,
+		"totalIntakeMl":   totalIntakeML,
+		"totalPumpedMl":   totalPumpedML,
 	})
 }
 
-func getWeeklyReport(c *gin.Context, babyID string, endDate time.Time) {
+func getWeeklyReport(c *gin.Context, baby models.Baby, endDate time.Time) {
 	startDate := endDate.AddDate(0, 0, -6) // 7 days including end date
-	startOfFirstDay := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 23, 0, 0, 0, startDate.Location())
-	endOfLastDay := time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 0, 0, 0, endDate.Location())
-	currentDay := time.Now().Truncate(24 * time.Hour)
 
-	var dailySummaries []DailySummary
-	var totalSleepHours float64
-	var daysWithSleep, totalDiapers, daysWithDiapers, totalNursings, daysWithNursings int
-
-	// Calculate summaries for each day
-	for d := startOfFirstDay; d.Before(endOfLastDay.Add(time.Second)); d = d.AddDate(0, 0, 1) {
-		dayEnd := d.AddDate(0, 0, 1) // Next day at 23:00
-
-		// Get sleeps for the day
-		var sleeps []models.Sleep
-		if err := database.DB.Where("baby_id = ? AND (start < ? AND \"end\" > ? OR start BETWEEN ? AND ? OR \"end\" BETWEEN ? AND ?)",
-			babyID, dayEnd, d, d, dayEnd, d, dayEnd).Find(&sleeps).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		// Calculate total sleep hours for the day
-		var dailySleepHours float64
-		for _, sleep := range sleeps {
-			sleepStart := sleep.Start
-			sleepEnd := sleep.End
-			if sleepStart.Before(d) {
-				sleepStart = d
-			}
-			if sleepEnd.After(dayEnd) {
-				sleepEnd = dayEnd
-			}
-			dailySleepHours += sleepEnd.Sub(sleepStart).Hours()
-		}
+	days, err := aggregateEvents(baby, startDate, endDate, granularityDay)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-		// Get diapers for the day
-		var diapers []models.Diaper
-		if err := database.DB.Where("baby_id = ? AND time >= ? AND time < ?",
-			babyID, d, dayEnd).Find(&diapers).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+	c.JSON(http.StatusOK, summarizeDays(baby, startDate, endDate, days))
+}
 
-		// Get nursings for the day
-		var nursings []models.Nursing
-		if err := database.DB.Where("baby_id = ? AND time >= ? AND time < ?",
-			babyID, d, dayEnd).Find(&nursings).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+// summarizeDays builds the report of the days from startDate to endDate out
+// of their aggregates. Averages skip days without any entry of a kind and the
+// current day, which isn't over yet.
+func summarizeDays(baby models.Baby, startDate, endDate time.Time, days []PeriodSummary) WeeklyReport {
+	startOfFirstDay, _ := babyDayBounds(baby, startDate)
+	startOfLastDay, endOfLastDay := babyDayBounds(baby, endDate)
+	startOfCurrentDay, _ := babyDayBounds(baby, babyToday(baby, time.Now()))
 
-		summary := DailySummary{
-			Date:            d,
-			TotalHoursSlept: dailySleepHours,
-			DiaperCount:     len(diapers),
-			NursingCount:    len(nursings),
-		}
-		dailySummaries = append(dailySummaries, summary)
+	var dailySummaries []DailySummary
+	var totalSleepHours float64
+	var daysWithSleep, totalDiapers, daysWithDiapers, totalNursings, daysWithNursings int
+	var totalIntakeML float64
+	var daysWithIntake int
+
+	for _, day := range days {
+		dailySummaries = append(dailySummaries, DailySummary{
+			Date:                   day.Start,
+			TotalHoursSlept:        day.TotalHoursSlept,
+			DiaperCount:            day.DiaperCount,
+			NursingCount:           day.NursingCount,
+			IntakeML:               day.IntakeML,
+			AvgFeedIntervalMinutes: day.AvgFeedIntervalMinutes,
+		})
 
 		// Update totals for averages, excluding current day
-		if !d.Truncate(24 * time.Hour).Equal(currentDay) {
-			if dailySleepHours > 0 {
-				totalSleepHours += dailySleepHours
+		if !day.Start.Equal(startOfCurrentDay) {
+			if day.TotalHoursSlept > 0 {
+				totalSleepHours += day.TotalHoursSlept
 				daysWithSleep++
 			}
-			if len(diapers) > 0 {
-				totalDiapers += len(diapers)
+			if day.DiaperCount > 0 {
+				totalDiapers += day.DiaperCount
 				daysWithDiapers++
 			}
-			if len(nursings) > 0 {
-				totalNursings += len(nursings)
+			if day.NursingCount > 0 {
+				totalNursings += day.NursingCount
 				daysWithNursings++
 			}
+			if day.IntakeML > 0 {
+				totalIntakeML += day.IntakeML
+				daysWithIntake++
+			}
 		}
 	}
 
@@ -205,20 +382,24 @@ func getWeeklyReport(c *gin.Context, babyID string, endDate time.Time) {
 		avgNursings = float64(totalNursings) / float64(daysWithNursings)
 	}
 
-	report := WeeklyReport{
+	avgIntake := 0.0
+	if daysWithIntake > 0 {
+		avgIntake = totalIntakeML / float64(daysWithIntake)
+	}
+
+	return WeeklyReport{
 		StartDate:         startOfFirstDay,
 		EndDate:           endOfLastDay,
 		DailySummaries:    reverseDailySummaries(dailySummaries),
 		AvgSleepHours:     avgSleepHours,
 		AvgDiapersPerDay:  avgDiapers,
 		AvgNursingsPerDay: avgNursings,
+		AvgIntakeMLPerDay: avgIntake,
+		Age:               babyAge(baby, startOfLastDay),
 	}
-
-	c.JSON(http.StatusOK, report)
 }
 
 func reverseDailySummaries(summaries []DailySummary) []DailySummary {
-	summaries = summaries[:len(summaries)-1]
 	for i, j := 0, len(summaries)-1; i < j; i, j = i+1, j-1 {
 		summaries[i], summaries[j] = summaries[j], summaries[i]
 	}
@@ -227,39 +408,21 @@ func reverseDailySummaries(summaries []DailySummary) []DailySummary {
 
 func SetupReportRoutes(api *gin.RouterGroup) {
 	report := api.Group("/report")
-	report.Use(AuthMiddleware()) // Add authentication middleware
+	report.Use(AuthMiddleware(), checkBabyParamAccess()) // Every route is about the baby of :id
 	{
 		report.GET("/:id", func(c *gin.Context) {
-			// Get user from context
-			userInterface, _ := c.Get("user")
-			user := userInterface.(models.User)
-
 			babyID := c.Param("id")
 			dateStr := c.Query("date") // expects date in format "2006-01-02"
 
-			// Check if user has access to this baby
-			if err := database.DB.Preload("Babies").First(&user, "id = ?", user.ID).Error; err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-				return
-			}
-
-			hasAccess := false
-			for _, baby := range user.Babies {
-				if baby.ID == babyID {
-					hasAccess = true
-					break
-				}
-			}
-
-			if !hasAccess {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this baby"})
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
 				return
 			}
 
 			var date time.Time
 			var err error
 			if dateStr == "" {
-				date = time.Now()
+				date = babyToday(baby, time.Now())
 			} else {
 				date, err = time.Parse("2006-01-02", dateStr)
 				if err != nil {
@@ -268,32 +431,14 @@ func SetupReportRoutes(api *gin.RouterGroup) {
 				}
 			}
 
-			getDailyReport(c, babyID, date)
+			getDailyReport(c, baby, date)
 		})
 
 		report.GET("/:id/date/:year/:month/:day", func(c *gin.Context) {
-			// Get user from context
-			userInterface, _ := c.Get("user")
-			user := userInterface.(models.User)
-
 			babyID := c.Param("id")
 
-			// Check if user has access to this baby
-			if err := database.DB.Preload("Babies").First(&user, "id = ?", user.ID).Error; err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-				return
-			}
-
-			hasAccess := false
-			for _, baby := range user.Babies {
-				if baby.ID == babyID {
-					hasAccess = true
-					break
-				}
-			}
-
-			if !hasAccess {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this baby"})
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
 				return
 			}
 
@@ -308,32 +453,14 @@ func SetupReportRoutes(api *gin.RouterGroup) {
 				return
 			}
 
-			getDailyReport(c, babyID, date)
+			getDailyReport(c, baby, date)
 		})
 
 		report.GET("/:id/weekly", func(c *gin.Context) {
-			// Get user from context
-			userInterface, _ := c.Get("user")
-			user := userInterface.(models.User)
-
 			babyID := c.Param("id")
 
-			// Check if user has access to this baby
-			if err := database.DB.Preload("Babies").First(&user, "id = ?", user.ID).Error; err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-				return
-			}
-
-			hasAccess := false
-			for _, baby := range user.Babies {
-				if baby.ID == babyID {
-					hasAccess = true
-					break
-				}
-			}
-
-			if !hasAccess {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this baby"})
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
 				return
 			}
 
@@ -341,7 +468,7 @@ func SetupReportRoutes(api *gin.RouterGroup) {
 
 			var endDate time.Time
 			if dateStr == "" {
-				endDate = time.Now()
+				endDate = babyToday(baby, time.Now())
 			} else {
 				var err error
 				endDate, err = time.Parse("2006-01-02", dateStr)
@@ -351,32 +478,110 @@ func SetupReportRoutes(api *gin.RouterGroup) {
 				}
 			}
 
-			getWeeklyReport(c, babyID, endDate)
+			getWeeklyReport(c, baby, endDate)
 		})
 
-		report.GET("/:id/history/:date", func(c *gin.Context) {
-			// Get user from context
-			userInterface, _ := c.Get("user")
-			user := userInterface.(models.User)
+		// GET /api/report/:id/range?from=&to=&granularity= - Totals per day,
+		// week or month between two report days, to defaulting to today
+		report.GET("/:id/range", func(c *gin.Context) {
+			babyID := c.Param("id")
+
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
+				return
+			}
+
+			from, to, ok := parseReportRange(c, baby)
+			if !ok {
+				return
+			}
+			if to.Sub(from).Hours()/24 >= maxRangeDays {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A range report covers at most 5 years"})
+				return
+			}
+
+			granularity := c.DefaultQuery("granularity", granularityDay)
+			if _, ok := granularitySteps[granularity]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "granularity must be day, week or month"})
+				return
+			}
+
+			periods, err := aggregateEvents(baby, from, to, granularity)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
 
+			startOfFirstDay, _ := babyDayBounds(baby, from)
+			_, endOfLastDay := babyDayBounds(baby, to)
+			c.JSON(http.StatusOK, RangeReport{
+				StartDate:   startOfFirstDay,
+				EndDate:     endOfLastDay,
+				Granularity: granularity,
+				Periods:     periods,
+			})
+		})
+
+		// GET /api/report/:id/monthly?month=YYYY-MM - Trend report of a
+		// calendar month, the current one by default, up to today
+		report.GET("/:id/monthly", func(c *gin.Context) {
 			babyID := c.Param("id")
 
-			// Check if user has access to this baby
-			if err := database.DB.Preload("Babies").First(&user, "id = ?", user.ID).Error; err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
 				return
 			}
 
-			hasAccess := false
-			for _, baby := range user.Babies {
-				if baby.ID == babyID {
-					hasAccess = true
-					break
+			today := babyToday(baby, time.Now())
+			from := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+			if monthStr := c.Query("month"); monthStr != "" {
+				var err error
+				from, err = time.Parse("2006-01", monthStr)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month format. Use YYYY-MM"})
+					return
 				}
 			}
+			if from.After(today) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "The month has not started yet"})
+				return
+			}
+
+			to := from.AddDate(0, 1, -1)
+			if to.After(today) {
+				to = today
+			}
+
+			getTrendReport(c, baby, from, to)
+		})
+
+		// GET /api/report/:id/trend?from=&to= - Trend report between two
+		// report days, to defaulting to today
+		report.GET("/:id/trend", func(c *gin.Context) {
+			babyID := c.Param("id")
+
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
+				return
+			}
+
+			from, to, ok := parseReportRange(c, baby)
+			if !ok {
+				return
+			}
+			if to.Sub(from).Hours()/24 >= maxTrendDays {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A trend report covers at most a year"})
+				return
+			}
+
+			getTrendReport(c, baby, from, to)
+		})
+
+		report.GET("/:id/history/:date", func(c *gin.Context) {
+			babyID := c.Param("id")
 
-			if !hasAccess {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this baby"})
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
 				return
 			}
 
@@ -386,7 +591,7 @@ func SetupReportRoutes(api *gin.RouterGroup) {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
 				return
 			}
-			getDailyReport(c, babyID, date)
+			getDailyReport(c, baby, date)
 		})
 	}
 }
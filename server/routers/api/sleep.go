@@ -3,12 +3,14 @@ package api
 import (
 	"baby-tracker/database"
 	"baby-tracker/models"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 func SetupSleepRoutes(api *gin.RouterGroup) {
@@ -17,6 +19,7 @@ func SetupSleepRoutes(api *gin.RouterGroup) {
 	{
 		sleep.POST("", checkBabyAccess(), func(c *gin.Context) {
 			var sleepInput struct {
+				ID     string `json:"id"` // client generated, makes retries safe
 				Start  string `json:"start"`
 				End    string `json:"end"`
 				BabyID string `json:"babyId"`
@@ -39,15 +42,36 @@ func SetupSleepRoutes(api *gin.RouterGroup) {
 				return
 			}
 
+			if end.Before(start) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "End time must be after start time"})
+				return
+			}
+
+			id, err := recordID(c, sleepInput.ID, "sleep")
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			var existing models.Sleep
+			if replayCreate(c, &existing, id, sleepInput.BabyID, func() gin.H { return formatSleep(existing) }) {
+				return
+			}
+
 			// Store times in UTC
+			endUTC := end.UTC()
 			sleep := models.Sleep{
-				ID:     uuid.NewString(),
+				ID:     id,
 				Start:  start.UTC(),
-				End:    end.UTC(),
+				End:    &endUTC,
 				BabyID: sleepInput.BabyID,
 			}
 
 			if err := database.DB.Create(&sleep).Error; err != nil {
+				// A concurrent attempt of the same request may have inserted it
+				if replayCreate(c, &existing, id, sleep.BabyID, func() gin.H { return formatSleep(existing) }) {
+					return
+				}
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
@@ -61,81 +85,280 @@ func SetupSleepRoutes(api *gin.RouterGroup) {
 			})
 		})
 
-		sleep.GET("", func(c *gin.Context) {
-			// Get user from context
-			userInterface, _ := c.Get("user")
-			user := userInterface.(models.User)
-
+		sleep.GET("", checkBabyAccess(), func(c *gin.Context) {
 			// Get babyId from query parameter
 			babyID := c.Query("babyId")
-			if babyID == "" {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Baby ID not provided"})
+
+			var sleeps []models.Sleep
+			if err := database.DB.Where("baby_id = ?", babyID).Find(&sleeps).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 
-			// Check if user has access to this baby
-			if err := database.DB.Preload("Babies").First(&user, "id = ?", user.ID).Error; err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			// Convert times to RFC3339 format
+			response := make([]gin.H, len(sleeps))
+			for i, sleep := range sleeps {
+				response[i] = formatSleep(sleep)
+			}
+			c.JSON(http.StatusOK, response)
+		})
+
+		// Timer endpoints: a sleep without an end time is persisted so that any
+		// parent of the baby can see it running and stop it from another device.
+		sleep.POST("/start", checkBabyAccess(), func(c *gin.Context) {
+			var timerInput struct {
+				Start  string `json:"start"`
+				BabyID string `json:"babyId"`
+				Note   string `json:"note"`
+			}
+			if err := c.ShouldBindJSON(&timerInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
 
-			hasAccess := false
-			for _, baby := range user.Babies {
-				if baby.ID == babyID {
-					hasAccess = true
-					break
+			start := time.Now()
+			if timerInput.Start != "" {
+				var err error
+				start, err = time.Parse(time.RFC3339, timerInput.Start)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start time format"})
+					return
 				}
 			}
 
-			if !hasAccess {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this baby"})
+			if runningSleepConflict(c, timerInput.BabyID) {
 				return
 			}
 
-			var sleeps []models.Sleep
-			if err := database.DB.Where("baby_id = ?", babyID).Find(&sleeps).Error; err != nil {
+			sleep := models.Sleep{
+				ID:         uuid.NewString(),
+				Start:      start.UTC(),
+				InProgress: true,
+				BabyID:     timerInput.BabyID,
+				Note:       timerInput.Note,
+			}
+			if err := database.DB.Create(&sleep).Error; err != nil {
+				// Another parent started one since the check
+				if errors.Is(err, gorm.ErrDuplicatedKey) && runningSleepConflict(c, sleep.BabyID) {
+					return
+				}
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 
-			// Convert times to RFC3339 format
-			response := make([]gin.H, len(sleeps))
-			for i, sleep := range sleeps {
-				response[i] = gin.H{
-					"id":     sleep.ID,
-					"start":  sleep.Start.Format(time.RFC3339),
-					"end":    sleep.End.Format(time.RFC3339),
-					"babyId": sleep.BabyID,
+			c.JSON(http.StatusOK, formatSleep(sleep))
+		})
+
+		sleep.GET("/active", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			var running models.Sleep
+			if err := database.DB.Where("baby_id = ? AND in_progress = ?", babyID, true).First(&running).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "No sleep in progress"})
+				return
+			}
+
+			c.JSON(http.StatusOK, formatSleep(running))
+		})
+
+		sleep.POST("/:id/stop", checkRecordAccess("sleeps", "Sleep"), func(c *gin.Context) {
+			var stopInput struct {
+				End  string `json:"end"`
+				Note string `json:"note"`
+			}
+			if err := bindOptionalJSON(c, &stopInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			var sleep models.Sleep
+			if err := database.DB.First(&sleep, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Sleep not found"})
+				return
+			}
+
+			if !sleep.InProgress {
+				c.JSON(http.StatusConflict, gin.H{"error": "Sleep is not in progress"})
+				return
+			}
+
+			end := time.Now()
+			if stopInput.End != "" {
+				var err error
+				end, err = time.Parse(time.RFC3339, stopInput.End)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end time format"})
+					return
 				}
 			}
-			c.JSON(http.StatusOK, response)
+			if end.Before(sleep.Start) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "End time must be after start time"})
+				return
+			}
+
+			endUTC := end.UTC()
+			sleep.End = &endUTC
+			sleep.InProgress = false
+			if stopInput.Note != "" {
+				sleep.Note = stopInput.Note
+			}
+			sleep.Version++
+			err := saveVersion(database.DB, &sleep, sleep.Version-1)
+			if errors.Is(err, errVersionConflict) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Sleep was changed by someone else, reload it"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, formatSleep(sleep))
 		})
 
-		sleep.DELETE("/:id", checkBabyAccess(), func(c *gin.Context) {
-			id := c.Param("id")
-			if err := database.DB.Delete(&models.Sleep{}, "id = ?", id).Error; err != nil {
+		// Resume reopens a sleep that was stopped too early, e.g. when the baby
+		// woke up briefly and went straight back to sleep.
+		sleep.POST("/:id/resume", checkRecordAccess("sleeps", "Sleep"), func(c *gin.Context) {
+			var sleep models.Sleep
+			if err := database.DB.First(&sleep, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Sleep not found"})
+				return
+			}
+
+			if sleep.InProgress {
+				c.JSON(http.StatusConflict, gin.H{"error": "Sleep is already in progress"})
+				return
+			}
+
+			if runningSleepConflict(c, sleep.BabyID) {
+				return
+			}
+
+			sleep.End = nil
+			sleep.InProgress = true
+			sleep.Version++
+			err := saveVersion(database.DB, &sleep, sleep.Version-1)
+			if errors.Is(err, errVersionConflict) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Sleep was changed by someone else, reload it"})
+				return
+			}
+			if errors.Is(err, gorm.ErrDuplicatedKey) && runningSleepConflict(c, sleep.BabyID) {
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, formatSleep(sleep))
+		})
+
+		sleep.POST("/:id/cancel", checkRecordAccess("sleeps", "Sleep"), func(c *gin.Context) {
+			var sleep models.Sleep
+			if err := database.DB.First(&sleep, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Sleep not found"})
+				return
+			}
+
+			if !sleep.InProgress {
+				c.JSON(http.StatusConflict, gin.H{"error": "Only a sleep in progress can be cancelled"})
+				return
+			}
+
+			if err := deleteRecords(database.DB, &models.Sleep{}, "id = ?", sleep.ID); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
 			c.JSON(http.StatusOK, gin.H{"success": true})
 		})
 
-		sleep.PUT("/:id", checkBabyAccess(), func(c *gin.Context) {
+		sleep.DELETE("/:id", checkRecordAccess("sleeps", "Sleep"), func(c *gin.Context) {
 			id := c.Param("id")
+			var existing models.Sleep
+			if err := database.DB.First(&existing, "id = ?", id).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Sleep not found"})
+				return
+			}
+
+			// The sleep goes to the trash, its attachments stay until it is purged
+			if err := deleteRecords(database.DB, &models.Sleep{}, "id = ?", id); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+
+		// Restore takes a deleted sleep out of the trash, e.g. to undo a
+		// delete
+		sleep.POST("/:id/restore", checkRecordAccess("sleeps", "Sleep"), func(c *gin.Context) {
 			var sleep models.Sleep
-			if err := c.ShouldBindJSON(&sleep); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			if !loadTrashed(c, &sleep, "Sleep") {
+				return
+			}
+
+			if !checkRestorable(c, sleep.DeletedAt) {
 				return
 			}
-			sleep.ID = id
-			if err := database.DB.Save(&sleep).Error; err != nil {
+
+			// A cancelled timer only comes back if no other sleep is running
+			if sleep.InProgress && runningSleepConflict(c, sleep.BabyID) {
+				return
+			}
+
+			if err := database.DB.Transaction(func(tx *gorm.DB) error {
+				return restoreRecord(tx, "sleeps", &sleep, sleep.ID)
+			}); err != nil {
+				if errors.Is(err, gorm.ErrDuplicatedKey) && runningSleepConflict(c, sleep.BabyID) {
+					return
+				}
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
-			c.JSON(http.StatusOK, sleep)
+
+			c.JSON(http.StatusOK, formatSleep(sleep))
 		})
 
-		sleep.GET("/:id/date/:year/:month/:day", checkBabyAccess(), func(c *gin.Context) {
+		sleep.PUT("/:id", checkRecordAccess("sleeps", "Sleep"), func(c *gin.Context) {
+			id := c.Param("id")
+			var existing models.Sleep
+			if err := database.DB.First(&existing, "id = ?", id).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Sleep not found"})
+				return
+			}
+
+			version, ok := ifMatchVersion(c)
+			if !ok {
+				return
+			}
+			if version != existing.Version {
+				preconditionFailed(c, existing.Version, formatSleep(existing))
+				return
+			}
+
+			sleep := existing
+			if !bindUpdate(c, &sleep, &sleepEdit{}) {
+				return
+			}
+			sleep.Version = version + 1
+			err := saveVersion(database.DB, &sleep, version)
+			if errors.Is(err, errVersionConflict) {
+				// Another update got in since the version was checked
+				if err := database.DB.First(&existing, "id = ?", id).Error; err != nil {
+					c.JSON(http.StatusNotFound, gin.H{"error": "Sleep not found"})
+					return
+				}
+				preconditionFailed(c, existing.Version, formatSleep(existing))
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			setETag(c, sleep.Version)
+			c.JSON(http.StatusOK, formatSleep(sleep))
+		})
+
+		sleep.GET("/:id/date/:year/:month/:day", checkBabyParamAccess(), func(c *gin.Context) {
 			//get total hours slept in a day
 			year := c.Param("year")
 			month := c.Param("month")
@@ -147,14 +370,17 @@ func SetupSleepRoutes(api *gin.RouterGroup) {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format in URL. Use /id/YYYY/MM/DD"})
 				return
 			}
-			startOfDay := date.AddDate(0, 0, 0)
-			endOfDay := startOfDay.AddDate(0, 0, 1)
 			babyID := c.Param("id")
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
+				return
+			}
+			startOfDay, endOfDay := babyDayBounds(baby, date)
 
-			//get all sleeps in a day
+			//get all sleeps in a day, including one that is still running
 			var sleeps []models.Sleep
-			if err := database.DB.Where("baby_id = ? AND (start >= ? AND start < ? OR \"end\" >= ? AND \"end\" < ?)",
-				babyID, startOfDay, endOfDay, startOfDay, endOfDay).Find(&sleeps).Error; err != nil {
+			if err := database.DB.Where("baby_id = ? AND (start >= ? AND start < ? OR \"end\" >= ? AND \"end\" < ? OR in_progress AND start < ?)",
+				babyID, startOfDay, endOfDay, startOfDay, endOfDay, endOfDay).Find(&sleeps).Error; err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
@@ -163,3 +389,87 @@ func SetupSleepRoutes(api *gin.RouterGroup) {
 		})
 	}
 }
+
+// formatSleep converts a sleep to its API representation. Running sleeps have
+// a null end time.
+func formatSleep(sleep models.Sleep) gin.H {
+	return gin.H{
+		"id":         sleep.ID,
+		"start":      sleep.Start.Format(time.RFC3339),
+		"end":        formatOptionalTime(sleep.End),
+		"inProgress": sleep.InProgress,
+		"babyId":     sleep.BabyID,
+		"note":       sleep.Note,
+		"version":    sleep.Version,
+	}
+}
+
+// runningSleepConflict answers with the sleep already running for the baby,
+// if any, when another one would be started.
+func runningSleepConflict(c *gin.Context, babyID string) bool {
+	var running models.Sleep
+	if err := database.DB.Where("baby_id = ? AND in_progress = ?", babyID, true).First(&running).Error; err != nil {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "A sleep is already in progress", "sleep": formatSleep(running)})
+	return true
+}
+
+// sleepEdit is the body of a sleep edit, for PUT and batch operations: the
+// sleep as the API returns it.
+type sleepEdit struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end"`
+	Note  string     `json:"note"`
+	// InProgress is only read when a batch creates a running sleep
+	InProgress bool `json:"inProgress"`
+}
+
+// apply copies the editable fields onto sleep and validates them. Identity,
+// version and the timer state are left alone: only the timer endpoints start
+// and stop a sleep.
+func (edit sleepEdit) apply(sleep *models.Sleep) error {
+	sleep.Start = edit.Start.UTC()
+	sleep.End = nil
+	if edit.End != nil {
+		end := edit.End.UTC()
+		sleep.End = &end
+	}
+	sleep.Note = edit.Note
+	return validateSleepTimes(*sleep)
+}
+
+// validateSleepTimes checks that a finished sleep ends after it started and
+// that a running one has no end yet.
+func validateSleepTimes(sleep models.Sleep) error {
+	if sleep.InProgress {
+		if sleep.End != nil {
+			return errors.New("A sleep in progress has no end time, stop it instead")
+		}
+		return nil
+	}
+	if sleep.End == nil {
+		return errors.New("End time is required")
+	}
+	if sleep.End.Before(sleep.Start) {
+		return errors.New("End time must be after start time")
+	}
+	return nil
+}
+
+// sleepEnd returns the end of a sleep, treating a running sleep as ending now.
+func sleepEnd(sleep models.Sleep, now time.Time) time.Time {
+	if sleep.End == nil {
+		return now
+	}
+	return *sleep.End
+}
+
+// formatOptionalTime formats t as RFC3339, or returns nil so it is encoded as
+// JSON null.
+func formatOptionalTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
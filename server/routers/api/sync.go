@@ -0,0 +1,209 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// syncOverlap is how far before the cursor changes are looked up again. A
+// record is stamped when it is saved but only becomes visible once its
+// transaction commits, which can be after a sync that started later. Clients
+// upsert by id, so seeing a record twice is harmless.
+const syncOverlap = 30 * time.Second
+
+// syncedEntities are the tables exposed by the change feed, keyed by table
+// name, with a factory for the slice their rows are loaded into. Milk
+// consumptions are left out: they only link feeds to the stash entries they
+// drew from, whose remaining volume is synced.
+var syncedEntities = []struct {
+	table   string
+	records func() interface{}
+}{
+	{"sleeps", func() interface{} { return &[]models.Sleep{} }},
+	{"diapers", func() interface{} { return &[]models.Diaper{} }},
+	{"nursings", func() interface{} { return &[]models.Nursing{} }},
+	{"measurements", func() interface{} { return &[]models.Measurement{} }},
+	{"medication_doses", func() interface{} { return &[]models.MedicationDose{} }},
+	{"temperatures", func() interface{} { return &[]models.Temperature{} }},
+	{"symptoms", func() interface{} { return &[]models.Symptom{} }},
+	{"vaccinations", func() interface{} { return &[]models.Vaccination{} }},
+	{"food_introductions", func() interface{} { return &[]models.FoodIntroduction{} }},
+	{"milestones", func() interface{} { return &[]models.Milestone{} }},
+	{"custom_events", func() interface{} { return &[]models.CustomEvent{} }},
+	// Episodes come without their symptoms, which are synced on their own
+	{"illness_episodes", func() interface{} { return &[]models.IllnessEpisode{} }},
+	{"medications", func() interface{} { return &[]models.Medication{} }},
+	{"event_types", func() interface{} { return &[]models.EventType{} }},
+	{"milk_stashes", func() interface{} { return &[]models.MilkStash{} }},
+}
+
+func SetupSyncRoutes(api *gin.RouterGroup) {
+	sync := api.Group("/sync")
+	sync.Use(AuthMiddleware()) // Add authentication middleware
+	{
+		// GET /api/sync?babyId=&since= - Records created, updated or deleted
+		// since the cursor of a previous sync, everything without one
+		sync.GET("", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			var since *time.Time
+			if cursor := c.Query("since"); cursor != "" {
+				t, err := time.Parse(time.RFC3339Nano, cursor)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+					return
+				}
+				t = t.Add(-syncOverlap)
+				since = &t
+			}
+
+			// Taken before reading so that nothing saved meanwhile is skipped
+			cursor := time.Now().UTC()
+
+			changes := gin.H{}
+			for _, entity := range syncedEntities {
+				records := entity.records()
+				query := database.DB.Table(entity.table).Where("baby_id = ?", babyID)
+				if since != nil {
+					query = query.Where("updated_at > ?", *since)
+				}
+				if err := query.Order("updated_at").Find(records).Error; err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				changes[entity.table] = records
+			}
+
+			// A full sync starts from an empty store, it has nothing to delete
+			tombstones := []models.Tombstone{}
+			if since != nil {
+				if err := database.DB.Where("baby_id = ? AND deleted_at > ?", babyID, *since).
+					Order("deleted_at").Find(&tombstones).Error; err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"changes":    changes,
+				"deleted":    tombstones,
+				"cursor":     cursor.Format(time.RFC3339Nano),
+				"fullResync": since == nil,
+			})
+		})
+
+		// POST /api/sync/batch - Apply the operations a client queued while
+		// offline, in order and in one transaction. A failed operation is
+		// rolled back on its own and reported in its result, the others are
+		// still applied.
+		sync.POST("/batch", checkBabyAccess(), func(c *gin.Context) {
+			var batchInput struct {
+				BabyID     string           `json:"babyId"`
+				Operations []batchOperation `json:"operations"`
+			}
+			if err := c.ShouldBindJSON(&batchInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if len(batchInput.Operations) > maxBatchOperations {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A batch can hold at most %d operations", maxBatchOperations)})
+				return
+			}
+
+			results := make([]gin.H, len(batchInput.Operations))
+			if err := database.DB.Transaction(func(tx *gorm.DB) error {
+				for i, op := range batchInput.Operations {
+					result := gin.H{"index": i, "op": op.Op, "type": op.Type}
+					results[i] = result
+
+					if op.ID == "" && op.Op != "create" {
+						result["status"] = http.StatusBadRequest
+						result["error"] = "id is required"
+						continue
+					}
+					id, err := keyedRecordID(c, op.ID, op.IdempotencyKey, op.Type)
+					if err != nil {
+						result["status"] = http.StatusBadRequest
+						result["error"] = err.Error()
+						continue
+					}
+					op.ID = id
+					result["id"] = id
+
+					var record gin.H
+					if err := tx.Transaction(func(tx *gorm.DB) error {
+						var err error
+						record, err = applyBatchOperation(tx, op, batchInput.BabyID)
+						return err
+					}); err != nil {
+						result["status"] = batchStatus(err)
+						result["error"] = err.Error()
+						if record != nil {
+							// The current record, for the client to merge into
+							result["current"] = record
+						}
+						continue
+					}
+
+					result["status"] = http.StatusOK
+					if record != nil {
+						result["record"] = record
+					}
+				}
+				return nil
+			}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"results": results})
+		})
+	}
+}
+
+// deleteRecords deletes the records of model matching the conditions and
+// leaves a tombstone for each of them, so that syncing clients drop their
+// copies too.
+func deleteRecords(tx *gorm.DB, model interface{}, query interface{}, args ...interface{}) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	return tx.Transaction(func(tx *gorm.DB) error {
+		var deleted []struct {
+			ID     string
+			BabyID string
+		}
+		if err := tx.Model(model).Select("id", "baby_id").Where(query, args...).Find(&deleted).Error; err != nil {
+			return err
+		}
+		if len(deleted) == 0 {
+			return nil
+		}
+
+		now := time.Now().UTC()
+		tombstones := make([]models.Tombstone, len(deleted))
+		for i, record := range deleted {
+			tombstones[i] = models.Tombstone{
+				ID:         uuid.NewString(),
+				BabyID:     record.BabyID,
+				EntityType: stmt.Schema.Table,
+				EntityID:   record.ID,
+				DeletedAt:  now,
+			}
+		}
+		if err := tx.Create(&tombstones).Error; err != nil {
+			return err
+		}
+
+		return tx.Where(query, args...).Delete(model).Error
+	})
+}
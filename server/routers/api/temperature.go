@@ -0,0 +1,237 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Fever levels of a temperature reading
+const (
+	feverNone = "normal"
+	feverLow  = "low"
+	feverMild = "fever"
+	feverHigh = "high" // call a doctor
+)
+
+// feverFlag is how concerning a temperature is for a baby of a given age.
+type feverFlag struct {
+	Level      string  `json:"level"`
+	ThresholdC float64 `json:"thresholdC"` // reading from which a doctor should be called
+	Advice     string  `json:"advice,omitempty"`
+}
+
+// axillaryOffsetC is how much lower an armpit reading is than a rectal one.
+const axillaryOffsetC = 0.5
+
+func SetupTemperatureRoutes(api *gin.RouterGroup) {
+	temperature := api.Group("/temperature")
+	temperature.Use(AuthMiddleware()) // Add authentication middleware
+	{
+		temperature.POST("", checkBabyAccess(), func(c *gin.Context) {
+			var input temperatureInput
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			temperature := models.Temperature{ID: uuid.NewString(), BabyID: input.BabyID}
+			if err := input.apply(&temperature); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			baby, ok := loadBaby(c, temperature.BabyID)
+			if !ok {
+				return
+			}
+
+			if err := database.DB.Create(&temperature).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, formatTemperature(temperature, baby))
+		})
+
+		temperature.GET("", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
+				return
+			}
+
+			var temperatures []models.Temperature
+			if err := database.DB.Where("baby_id = ?", babyID).Order("time").Find(&temperatures).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			response := make([]gin.H, len(temperatures))
+			for i, temperature := range temperatures {
+				response[i] = formatTemperature(temperature, baby)
+			}
+			c.JSON(http.StatusOK, response)
+		})
+
+		temperature.PUT("/:id", checkRecordAccess("temperatures", "Temperature"), func(c *gin.Context) {
+			var temperature models.Temperature
+			if err := database.DB.First(&temperature, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Temperature not found"})
+				return
+			}
+
+			if !bindUpdate(c, &temperature, &temperatureInput{}) {
+				return
+			}
+
+			baby, ok := loadBaby(c, temperature.BabyID)
+			if !ok {
+				return
+			}
+
+			if err := database.DB.Save(&temperature).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, formatTemperature(temperature, baby))
+		})
+
+		temperature.DELETE("/:id", checkRecordAccess("temperatures", "Temperature"), func(c *gin.Context) {
+			var temperature models.Temperature
+			if err := database.DB.First(&temperature, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Temperature not found"})
+				return
+			}
+
+			if err := deleteRecords(database.DB, &models.Temperature{}, "id = ?", temperature.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+	}
+}
+
+// temperatureInput is the body of a temperature create or update. BabyID is
+// only read on create.
+type temperatureInput struct {
+	Time   string  `json:"time"`
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit"`
+	Method string  `json:"method"`
+	BabyID string  `json:"babyId"`
+	Note   string  `json:"note"`
+}
+
+func (input temperatureInput) apply(temperature *models.Temperature) error {
+	temperatureTime, err := time.Parse(time.RFC3339, input.Time)
+	if err != nil {
+		return errors.New("Invalid time format")
+	}
+
+	temperature.Time = temperatureTime.UTC()
+	temperature.Value = input.Value
+	temperature.Unit = input.Unit
+	temperature.Method = input.Method
+	temperature.Note = input.Note
+	return normalizeTemperature(temperature)
+}
+
+// normalizeTemperature validates the unit and method of a reading and fills
+// in its value in Celsius.
+func normalizeTemperature(temperature *models.Temperature) error {
+	switch strings.ToUpper(temperature.Unit) {
+	case "", "C":
+		temperature.Unit = "C"
+		temperature.Celsius = temperature.Value
+	case "F":
+		temperature.Unit = "F"
+		temperature.Celsius = math.Round((temperature.Value-32)*5/9*100) / 100
+	default:
+		return fmt.Errorf("Invalid unit %q, use C or F", temperature.Unit)
+	}
+
+	switch temperature.Method {
+	case models.TemperatureRectal, models.TemperatureAxillary, models.TemperatureEar:
+	case "":
+		temperature.Method = models.TemperatureRectal
+	default:
+		return fmt.Errorf("Invalid method %q, use rectal, axillary or ear", temperature.Method)
+	}
+
+	// Anything outside this range is a typo or a broken thermometer
+	if temperature.Celsius < 30 || temperature.Celsius > 45 {
+		return errors.New("Temperature is out of range")
+	}
+	return nil
+}
+
+// formatTemperature converts a temperature to its API representation, flagged
+// for the baby's age at the time of the reading.
+func formatTemperature(temperature models.Temperature, baby models.Baby) gin.H {
+	return gin.H{
+		"id":      temperature.ID,
+		"time":    temperature.Time.Format(time.RFC3339),
+		"value":   temperature.Value,
+		"unit":    temperature.Unit,
+		"celsius": temperature.Celsius,
+		"method":  temperature.Method,
+		"babyId":  temperature.BabyID,
+		"note":    temperature.Note,
+		"fever":   assessFever(temperature, baby),
+	}
+}
+
+// assessFever flags a reading following the usual paediatric advice: any
+// fever under 3 months needs a doctor, from 3 to 6 months a fever of 38.9°C,
+// and from then on a fever of 40°C. Without a birth date the most cautious
+// threshold is used.
+//
+// Under 3 months the doctor threshold is the fever threshold itself, so such a
+// baby never gets the mild level: any fever there is high on purpose.
+func assessFever(temperature models.Temperature, baby models.Baby) feverFlag {
+	core := temperature.Celsius
+	if temperature.Method == models.TemperatureAxillary {
+		core += axillaryOffsetC
+	}
+
+	flag := feverFlag{Level: feverNone, ThresholdC: 38.0}
+	newborn := false
+	if baby.BirthDate != nil {
+		ageDays := temperature.Time.Sub(*baby.BirthDate).Hours() / 24
+		switch {
+		case ageDays >= 180:
+			flag.ThresholdC = 40.0
+		case ageDays >= 90:
+			flag.ThresholdC = 38.9
+		default:
+			newborn = true
+		}
+	}
+
+	switch {
+	case core >= flag.ThresholdC:
+		flag.Level = feverHigh
+		flag.Advice = "Call a doctor"
+		// The age is unknown without a birth date, so the advice stays generic
+		if newborn {
+			flag.Advice = "Any fever under 3 months old needs a doctor right away"
+		}
+	case core >= 38.0:
+		flag.Level = feverMild
+	case core < 36.0:
+		flag.Level = feverLow
+		flag.Advice = "Low temperature, measure again and call a doctor if it stays low"
+	}
+	return flag
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"baby-tracker/models"
+	"testing"
+	"time"
+)
+
+func TestAssessFever(t *testing.T) {
+	birth := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	withBirth := models.Baby{BirthDate: &birth}
+	at := func(days int) time.Time { return birth.AddDate(0, 0, days) }
+
+	tests := []struct {
+		name          string
+		baby          models.Baby
+		temperature   models.Temperature
+		wantLevel     string
+		wantThreshold float64
+		wantAdvice    string
+	}{
+		{"no birth date, normal", models.Baby{}, models.Temperature{Celsius: 37.0, Time: at(10)}, feverNone, 38.0, ""},
+		{"no birth date, fever", models.Baby{}, models.Temperature{Celsius: 38.2, Time: at(10)}, feverHigh, 38.0, "Call a doctor"},
+		{"newborn, fever", withBirth, models.Temperature{Celsius: 38.0, Time: at(30)}, feverHigh, 38.0, "Any fever under 3 months old needs a doctor right away"},
+		{"newborn, axillary fever", withBirth, models.Temperature{Celsius: 37.6, Method: models.TemperatureAxillary, Time: at(30)}, feverHigh, 38.0, "Any fever under 3 months old needs a doctor right away"},
+		{"newborn, normal", withBirth, models.Temperature{Celsius: 37.9, Time: at(30)}, feverNone, 38.0, ""},
+		{"4 months, mild fever", withBirth, models.Temperature{Celsius: 38.5, Time: at(120)}, feverMild, 38.9, ""},
+		{"4 months, high fever", withBirth, models.Temperature{Celsius: 38.9, Time: at(120)}, feverHigh, 38.9, "Call a doctor"},
+		{"4 months, axillary high fever", withBirth, models.Temperature{Celsius: 38.4, Method: models.TemperatureAxillary, Time: at(120)}, feverHigh, 38.9, "Call a doctor"},
+		{"9 months, mild fever", withBirth, models.Temperature{Celsius: 39.5, Time: at(270)}, feverMild, 40.0, ""},
+		{"9 months, high fever", withBirth, models.Temperature{Celsius: 40.1, Time: at(270)}, feverHigh, 40.0, "Call a doctor"},
+		{"9 months, normal", withBirth, models.Temperature{Celsius: 37.2, Time: at(270)}, feverNone, 40.0, ""},
+		{"low", withBirth, models.Temperature{Celsius: 35.8, Time: at(270)}, feverLow, 40.0, "Low temperature, measure again and call a doctor if it stays low"},
+		{"axillary reading is not low", withBirth, models.Temperature{Celsius: 35.8, Method: models.TemperatureAxillary, Time: at(270)}, feverNone, 40.0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag := assessFever(tt.temperature, tt.baby)
+			if flag.Level != tt.wantLevel {
+				t.Errorf("Level = %q, want %q", flag.Level, tt.wantLevel)
+			}
+			if flag.ThresholdC != tt.wantThreshold {
+				t.Errorf("ThresholdC = %v, want %v", flag.ThresholdC, tt.wantThreshold)
+			}
+			if flag.Advice != tt.wantAdvice {
+				t.Errorf("Advice = %q, want %q", flag.Advice, tt.wantAdvice)
+			}
+		})
+	}
+}
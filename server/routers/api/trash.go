@@ -0,0 +1,172 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// trashModels are the events that go to the trash when deleted, keyed by
+// table name. They can be restored within the undo window and stay in the
+// trash until it is purged.
+var trashModels = map[string]interface{}{
+	"sleeps":   &models.Sleep{},
+	"diapers":  &models.Diaper{},
+	"nursings": &models.Nursing{},
+}
+
+// trashRetention returns how long deleted events stay in the trash, from
+// TRASH_RETENTION_DAYS, 30 days by default.
+func trashRetention() time.Duration {
+	if days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && days > 0 {
+		return time.Duration(days) * 24 * time.Hour
+	}
+	return 30 * 24 * time.Hour
+}
+
+// undoWindow returns how long after its deletion an event can be restored,
+// from UNDO_WINDOW (a duration such as "10m"). It defaults to the retention
+// and can't exceed it, since purged events are gone for good.
+func undoWindow() time.Duration {
+	retention := trashRetention()
+	if d, err := time.ParseDuration(os.Getenv("UNDO_WINDOW")); err == nil && d > 0 && d < retention {
+		return d
+	}
+	return retention
+}
+
+func SetupTrashRoutes(api *gin.RouterGroup) {
+	trash := api.Group("/trash")
+	trash.Use(AuthMiddleware()) // Add authentication middleware
+	{
+		// GET /api/trash?babyId= - Deleted events that can still be restored,
+		// most recently deleted first. expiresAt is when the trash purges them.
+		trash.GET("", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			retention, window := trashRetention(), undoWindow()
+			since := time.Now().Add(-window)
+			trashed := func(db *gorm.DB) *gorm.DB {
+				return db.Unscoped().Where("baby_id = ? AND deleted_at > ?", babyID, since)
+			}
+
+			var sleeps []models.Sleep
+			if err := database.DB.Scopes(trashed).Find(&sleeps).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			var diapers []models.Diaper
+			if err := database.DB.Scopes(trashed).Find(&diapers).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			var nursings []models.Nursing
+			if err := database.DB.Scopes(trashed).Find(&nursings).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			items := []gin.H{}
+			add := func(kind string, deletedAt gorm.DeletedAt, record gin.H) {
+				items = append(items, gin.H{
+					"type":            kind,
+					"deletedAt":       deletedAt.Time.UTC().Format(time.RFC3339),
+					"restorableUntil": deletedAt.Time.Add(window).UTC().Format(time.RFC3339),
+					"expiresAt":       deletedAt.Time.Add(retention).UTC().Format(time.RFC3339),
+					"record":          record,
+				})
+			}
+			for _, sleep := range sleeps {
+				add("sleep", sleep.DeletedAt, formatSleep(sleep))
+			}
+			for _, diaper := range diapers {
+				add("diaper", diaper.DeletedAt, formatDiaper(diaper))
+			}
+			for _, nursing := range nursings {
+				add("nursing", nursing.DeletedAt, formatNursing(nursing))
+			}
+			sort.SliceStable(items, func(i, j int) bool {
+				return items[i]["deletedAt"].(string) > items[j]["deletedAt"].(string)
+			})
+
+			c.JSON(http.StatusOK, items)
+		})
+	}
+}
+
+// loadTrashed loads a deleted record into record for restoring it, writing
+// the error response and returning false when it isn't in the trash anymore.
+func loadTrashed(c *gin.Context, record interface{}, name string) bool {
+	if err := database.DB.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", c.Param("id")).First(record).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": name + " not found in the trash"})
+		return false
+	}
+	return true
+}
+
+// checkRestorable writes the error response and returns false when a record
+// was deleted longer than the undo window ago, even if it wasn't purged yet.
+func checkRestorable(c *gin.Context, deletedAt gorm.DeletedAt) bool {
+	if time.Since(deletedAt.Time) > undoWindow() {
+		c.JSON(http.StatusGone, gin.H{"error": "The record was deleted too long ago to be restored"})
+		return false
+	}
+	return true
+}
+
+// restoreRecord takes a record out of the trash, as a new version so that
+// clients that synced its deletion get it back, and reloads it.
+func restoreRecord(tx *gorm.DB, table string, record interface{}, id string) error {
+	if err := tx.Unscoped().Model(record).Where("id = ?", id).Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"version":    gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("entity_type = ? AND entity_id = ?", table, id).Delete(&models.Tombstone{}).Error; err != nil {
+		return err
+	}
+	return tx.First(record, "id = ?", id).Error
+}
+
+// purgeTrash permanently deletes the events that have been in the trash for
+// longer than the retention, with their attachments.
+func purgeTrash() error {
+	before := time.Now().Add(-trashRetention())
+	for table, model := range trashModels {
+		var ids []string
+		if err := database.DB.Unscoped().Model(model).Where("deleted_at < ?", before).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if err := deleteWithAttachments(table, id, func(tx *gorm.DB) error {
+				return tx.Unscoped().Delete(model, "id = ?", id).Error
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// StartTrashPurge purges the trash in the background, at startup and then
+// every hour.
+func StartTrashPurge() {
+	go func() {
+		for {
+			if err := purgeTrash(); err != nil {
+				log.Printf("Failed to purge the trash: %v", err)
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUndoWindow(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		name      string
+		retention string
+		window    string
+		want      time.Duration
+	}{
+		{"defaults to the retention", "", "", 30 * day},
+		{"follows a custom retention", "7", "", 7 * day},
+		{"shorter window", "", "10m", 10 * time.Minute},
+		{"capped by the retention", "1", "48h", day},
+		{"invalid window", "", "soon", 30 * day},
+		{"negative window", "", "-5m", 30 * day},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRASH_RETENTION_DAYS", tt.retention)
+			t.Setenv("UNDO_WINDOW", tt.window)
+			if got := undoWindow(); got != tt.want {
+				t.Errorf("undoWindow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"baby-tracker/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rollingWindowDays is the length of the rolling averages of a trend report.
+const rollingWindowDays = 7
+
+// maxTrendDays bounds the length of a trend report, which has a series per day.
+const maxTrendDays = 366
+
+// TrendReport extends the weekly report to a month or any range of days with
+// rolling averages, the extreme days and week over week changes of each
+// metric of trendMetrics.
+type TrendReport struct {
+	WeeklyReport
+	RollingAverages []RollingAverage       `json:"rollingAverages"` // newest first, like dailySummaries
+	Extremes        map[string]DayExtremes `json:"extremes"`
+	Weeks           []WeekTrend            `json:"weeks"` // oldest first
+}
+
+// RollingAverage holds the averages of the rollingWindowDays days ending on
+// Date. A metric is nil when none of these days has a value.
+type RollingAverage struct {
+	Date   time.Time           `json:"date"`
+	Values map[string]*float64 `json:"values"`
+}
+
+// DayExtremes are the days with the lowest and highest value of a metric.
+type DayExtremes struct {
+	Min *DayValue `json:"min"`
+	Max *DayValue `json:"max"`
+}
+
+type DayValue struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+}
+
+// WeekTrend holds the daily averages of a calendar week of the range, and
+// their change from the previous week when both have a value.
+type WeekTrend struct {
+	StartDate time.Time           `json:"startDate"`
+	Days      int                 `json:"days"` // fewer at the ends of the range
+	Averages  map[string]*float64 `json:"averages"`
+	Deltas    map[string]*float64 `json:"deltas"`
+}
+
+// trendMetrics are the daily values followed by trend reports. A day without
+// any entry of a kind has no value and is left out, like in the report
+// averages.
+var trendMetrics = []struct {
+	name  string
+	value func(day PeriodSummary) (float64, bool)
+}{
+	{"sleepHours", func(day PeriodSummary) (float64, bool) {
+		return day.TotalHoursSlept, day.TotalHoursSlept > 0
+	}},
+	{"nursingCount", func(day PeriodSummary) (float64, bool) {
+		return float64(day.NursingCount), day.NursingCount > 0
+	}},
+	{"diaperCount", func(day PeriodSummary) (float64, bool) {
+		return float64(day.DiaperCount), day.DiaperCount > 0
+	}},
+	{"intakeMl", func(day PeriodSummary) (float64, bool) {
+		return day.IntakeML, day.IntakeML > 0
+	}},
+	{"feedIntervalMinutes", func(day PeriodSummary) (float64, bool) {
+		if day.AvgFeedIntervalMinutes == nil {
+			return 0, false
+		}
+		return *day.AvgFeedIntervalMinutes, true
+	}},
+}
+
+func getTrendReport(c *gin.Context, baby models.Baby, from, to time.Time) {
+	report, err := buildTrendReport(baby, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// buildTrendReport builds the trend report of the report days from from to
+// to. The current day isn't over yet and is left out of every statistic.
+func buildTrendReport(baby models.Baby, from, to time.Time) (TrendReport, error) {
+	// The days before the range complete the first rolling windows
+	days, err := aggregateEvents(baby, from.AddDate(0, 0, -(rollingWindowDays-1)), to, granularityDay)
+	if err != nil {
+		return TrendReport{}, err
+	}
+
+	startOfRange, _ := babyDayBounds(baby, from)
+	startOfCurrentDay, _ := babyDayBounds(baby, babyToday(baby, time.Now()))
+	first := 0
+	for first < len(days) && days[first].Start.Before(startOfRange) {
+		first++
+	}
+	counted := make([]PeriodSummary, 0, len(days))
+	for _, day := range days {
+		if !day.Start.Equal(startOfCurrentDay) {
+			counted = append(counted, day)
+		}
+	}
+
+	report := TrendReport{
+		WeeklyReport:    summarizeDays(baby, from, to, days[first:]),
+		RollingAverages: []RollingAverage{},
+		Extremes:        map[string]DayExtremes{},
+		Weeks:           []WeekTrend{},
+	}
+
+	for i := len(days) - 1; i >= first; i-- {
+		windowStart := days[i].Start.AddDate(0, 0, -(rollingWindowDays - 1))
+		var window []PeriodSummary
+		for _, day := range counted {
+			if !day.Start.Before(windowStart) && !day.Start.After(days[i].Start) {
+				window = append(window, day)
+			}
+		}
+		report.RollingAverages = append(report.RollingAverages, RollingAverage{
+			Date:   days[i].Start,
+			Values: metricAverages(window),
+		})
+	}
+
+	var inRange []PeriodSummary
+	for _, day := range counted {
+		if !day.Start.Before(startOfRange) {
+			inRange = append(inRange, day)
+		}
+	}
+
+	for _, metric := range trendMetrics {
+		var extremes DayExtremes
+		for _, day := range inRange {
+			value, ok := metric.value(day)
+			if !ok {
+				continue
+			}
+			if extremes.Min == nil || value < extremes.Min.Value {
+				extremes.Min = &DayValue{Date: day.Start, Value: value}
+			}
+			if extremes.Max == nil || value > extremes.Max.Value {
+				extremes.Max = &DayValue{Date: day.Start, Value: value}
+			}
+		}
+		report.Extremes[metric.name] = extremes
+	}
+
+	// Calendar weeks, starting on Monday
+	var week []PeriodSummary
+	var weekStart time.Time
+	flush := func() {
+		if len(week) == 0 {
+			return
+		}
+		trend := WeekTrend{
+			StartDate: weekStart,
+			Days:      len(week),
+			Averages:  metricAverages(week),
+			Deltas:    map[string]*float64{},
+		}
+		if len(report.Weeks) > 0 {
+			previous := report.Weeks[len(report.Weeks)-1]
+			for _, metric := range trendMetrics {
+				current, before := trend.Averages[metric.name], previous.Averages[metric.name]
+				if current != nil && before != nil {
+					delta := *current - *before
+					trend.Deltas[metric.name] = &delta
+				} else {
+					trend.Deltas[metric.name] = nil
+				}
+			}
+		}
+		report.Weeks = append(report.Weeks, trend)
+		week = nil
+	}
+	for _, day := range inRange {
+		monday := day.Start.AddDate(0, 0, -(int(day.Start.Weekday())+6)%7)
+		monday = time.Date(monday.Year(), monday.Month(), monday.Day(), 0, 0, 0, 0, time.UTC)
+		if !monday.Equal(weekStart) {
+			flush()
+			weekStart = monday
+		}
+		week = append(week, day)
+	}
+	flush()
+
+	return report, nil
+}
+
+// metricAverages returns the average of each trend metric over the days that
+// have a value for it, or nil when none has.
+func metricAverages(days []PeriodSummary) map[string]*float64 {
+	averages := map[string]*float64{}
+	for _, metric := range trendMetrics {
+		var total float64
+		var count int
+		for _, day := range days {
+			if value, ok := metric.value(day); ok {
+				total += value
+				count++
+			}
+		}
+		if count == 0 {
+			averages[metric.name] = nil
+			continue
+		}
+		average := total / float64(count)
+		averages[metric.name] = &average
+	}
+	return averages
+}
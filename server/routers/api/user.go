@@ -4,8 +4,11 @@ import (
 	"baby-tracker/database"
 	"baby-tracker/models"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 func SetupUserRoutes(api *gin.RouterGroup) {
@@ -25,7 +28,16 @@ func SetupUserRoutes(api *gin.RouterGroup) {
 				return
 			}
 
-			c.JSON(http.StatusOK, user)
+			response := gin.H{
+				"id":       user.ID,
+				"username": user.Username,
+			}
+			// The password reset flag is only shown to the user themselves
+			userInterface, _ := c.Get("user")
+			if currentUser := userInterface.(models.User); currentUser.ID == user.ID {
+				response["mustChangePassword"] = user.MustChangePassword
+			}
+			c.JSON(http.StatusOK, response)
 		})
 
 		// POST /api/user - Create new user
@@ -57,5 +69,154 @@ func SetupUserRoutes(api *gin.RouterGroup) {
 
 			c.JSON(http.StatusCreated, user)
 		})
+
+		// GET /api/user/sessions - List the user's signed-in devices
+		user.GET("/sessions", func(c *gin.Context) {
+			userInterface, _ := c.Get("user")
+			currentUser := userInterface.(models.User)
+
+			var sessions []models.Session
+			if err := database.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", currentUser.ID, time.Now()).
+				Order("last_used_at DESC").Find(&sessions).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			currentSessionID := c.GetString("sessionID")
+			response := make([]gin.H, len(sessions))
+			for i, session := range sessions {
+				response[i] = gin.H{
+					"id":         session.ID,
+					"deviceName": session.DeviceName,
+					"userAgent":  session.UserAgent,
+					"ip":         session.IP,
+					"createdAt":  session.CreatedAt,
+					"lastUsedAt": session.LastUsedAt,
+					"expiresAt":  session.ExpiresAt,
+					"current":    session.ID == currentSessionID,
+				}
+			}
+			c.JSON(http.StatusOK, response)
+		})
+
+		// DELETE /api/user/sessions/:id - Sign out a device
+		user.DELETE("/sessions/:id", func(c *gin.Context) {
+			userInterface, _ := c.Get("user")
+			currentUser := userInterface.(models.User)
+
+			result := database.DB.Model(&models.Session{}).
+				Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Param("id"), currentUser.ID).
+				Update("revoked_at", time.Now().UTC())
+			if result.Error != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+				return
+			}
+			if result.RowsAffected == 0 {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+
+		// DELETE /api/user - Delete the signed-in account
+		user.DELETE("", func(c *gin.Context) {
+			userInterface, _ := c.Get("user")
+			currentUser := userInterface.(models.User)
+
+			var deleteInput struct {
+				Password string `json:"password" binding:"required"`
+			}
+			if err := c.ShouldBindJSON(&deleteInput); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if err := bcrypt.CompareHashAndPassword([]byte(currentUser.Password), []byte(deleteInput.Password)); err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+				return
+			}
+
+			var blobKeys []string
+			if err := database.DB.Transaction(func(tx *gorm.DB) error {
+				var err error
+				blobKeys, err = deleteAccount(tx, currentUser.ID)
+				return err
+			}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			// Files of deleted babies go only once the rows are gone for good
+			removeBlobKeys(blobKeys)
+
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+	}
+}
+
+// deleteAccount removes a user and their memberships. Babies left without any
+// member are deleted with their history; babies left without an owner get
+// their longest-standing caregiver promoted, or viewer when there is none. It
+// returns the storage keys of the deleted babies' files, to remove once the
+// transaction has committed.
+func deleteAccount(tx *gorm.DB, userID string) ([]string, error) {
+	var memberships []models.UserBaby
+	var blobKeys []string
+	if err := tx.Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UserBaby{}).Error; err != nil {
+		return nil, err
+	}
+
+	for _, membership := range memberships {
+		var remaining []models.UserBaby
+		if err := tx.Where("baby_id = ?", membership.BabyID).Order("created_at").Find(&remaining).Error; err != nil {
+			return nil, err
+		}
+
+		if len(remaining) == 0 {
+			babyKeys, err := deleteBabyData(tx, membership.BabyID)
+			if err != nil {
+				return nil, err
+			}
+			blobKeys = append(blobKeys, babyKeys...)
+			continue
+		}
+
+		var owner, caregiver *models.UserBaby
+		for i := range remaining {
+			switch member := &remaining[i]; member.Role {
+			case models.RoleOwner:
+				owner = member
+			case models.RoleCaregiver:
+				if caregiver == nil {
+					caregiver = member
+				}
+			}
+		}
+		if owner == nil {
+			successor := &remaining[0]
+			if caregiver != nil {
+				successor = caregiver
+			}
+			if err := tx.Model(&models.UserBaby{}).
+				Where("user_id = ? AND baby_id = ?", successor.UserID, membership.BabyID).
+				Update("role", models.RoleOwner).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, model := range []interface{}{&models.Session{}, &models.PasswordResetToken{}} {
+		if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Delete(&models.User{}, "id = ?", userID).Error; err != nil {
+		return nil, err
 	}
+	return blobKeys, nil
 }
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetUserShowsPasswordFlagOnlyToThemselves(t *testing.T) {
+	setupTestDB(t)
+	r := newTestRouter()
+
+	flagged, flaggedToken := createTestUser(t, true)
+	_, otherToken := createTestUser(t, false)
+
+	var own map[string]interface{}
+	if code := doRequest(t, r, http.MethodGet, "/api/user", flaggedToken,
+		map[string]string{"X-Parrent-User-ID": flagged.ID}, nil, &own); code != http.StatusOK {
+		t.Fatalf("GET /api/user as the user returned %d", code)
+	}
+	if own["mustChangePassword"] != true {
+		t.Errorf("user doesn't see their own flag: %v", own)
+	}
+
+	var other map[string]interface{}
+	if code := doRequest(t, r, http.MethodGet, "/api/user", otherToken,
+		map[string]string{"X-Parrent-User-ID": flagged.ID}, nil, &other); code != http.StatusOK {
+		t.Fatalf("GET /api/user as another user returned %d", code)
+	}
+	if _, ok := other["mustChangePassword"]; ok {
+		t.Errorf("another user sees the flag: %v", other)
+	}
+}
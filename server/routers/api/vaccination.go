@@ -0,0 +1,231 @@
+package api
+
+import (
+	"baby-tracker/database"
+	"baby-tracker/models"
+	"baby-tracker/vaccines"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Status of a scheduled vaccine dose
+const (
+	vaccineGiven    = "given"
+	vaccineUpcoming = "upcoming"
+	vaccineDue      = "due"
+	vaccineOverdue  = "overdue"
+)
+
+func SetupVaccinationRoutes(api *gin.RouterGroup) {
+	vaccination := api.Group("/vaccination")
+	vaccination.Use(AuthMiddleware()) // Add authentication middleware
+	{
+		vaccination.POST("", checkBabyAccess(), func(c *gin.Context) {
+			var input vaccinationInput
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			vaccination := models.Vaccination{ID: uuid.NewString(), BabyID: input.BabyID}
+			if err := input.apply(&vaccination); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if err := database.DB.Create(&vaccination).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, vaccination)
+		})
+
+		vaccination.GET("", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			var vaccinations []models.Vaccination
+			if err := database.DB.Where("baby_id = ?", babyID).Order("date").Find(&vaccinations).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, vaccinations)
+		})
+
+		// GET /api/vaccination/schedules - Bundled national schedules
+		vaccination.GET("/schedules", func(c *gin.Context) {
+			schedules := []vaccines.Schedule{}
+			for _, country := range vaccines.Countries() {
+				schedule, _ := vaccines.Get(country)
+				schedules = append(schedules, schedule)
+			}
+			c.JSON(http.StatusOK, schedules)
+		})
+
+		// GET /api/vaccination/schedule?babyId= - The baby's schedule with the
+		// status of every dose
+		vaccination.GET("/schedule", checkBabyAccess(), func(c *gin.Context) {
+			babyID := c.Query("babyId")
+
+			baby, ok := loadBaby(c, babyID)
+			if !ok {
+				return
+			}
+
+			if baby.BirthDate == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Baby birth date is required for the vaccine schedule"})
+				return
+			}
+
+			schedule, ok := vaccines.Get(baby.VaccineSchedule)
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown vaccine schedule"})
+				return
+			}
+
+			var vaccinations []models.Vaccination
+			if err := database.DB.Where("baby_id = ?", babyID).Order("date").Find(&vaccinations).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, buildVaccineSchedule(*baby.BirthDate, schedule, vaccinations, time.Now()))
+		})
+
+		vaccination.PUT("/:id", checkRecordAccess("vaccinations", "Vaccination"), func(c *gin.Context) {
+			var vaccination models.Vaccination
+			if err := database.DB.First(&vaccination, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Vaccination not found"})
+				return
+			}
+
+			if !bindUpdate(c, &vaccination, &vaccinationInput{}) {
+				return
+			}
+
+			if err := database.DB.Save(&vaccination).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, vaccination)
+		})
+
+		vaccination.DELETE("/:id", checkRecordAccess("vaccinations", "Vaccination"), func(c *gin.Context) {
+			var vaccination models.Vaccination
+			if err := database.DB.First(&vaccination, "id = ?", c.Param("id")).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Vaccination not found"})
+				return
+			}
+
+			if err := deleteRecords(database.DB, &models.Vaccination{}, "id = ?", vaccination.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+	}
+}
+
+func validateVaccination(vaccination models.Vaccination) error {
+	if vaccination.Vaccine == "" {
+		return errors.New("vaccine is required")
+	}
+	if vaccination.DoseNumber < 1 {
+		return errors.New("doseNumber must be at least 1")
+	}
+	return nil
+}
+
+// vaccinationInput is the body of a vaccination create or update. BabyID is
+// only read on create.
+type vaccinationInput struct {
+	BabyID     string `json:"babyId"`
+	Vaccine    string `json:"vaccine"`
+	DoseNumber int    `json:"doseNumber"`
+	Date       string `json:"date"`
+	LotNumber  string `json:"lotNumber"`
+	Clinic     string `json:"clinic"`
+	Note       string `json:"note"`
+}
+
+func (input vaccinationInput) apply(vaccination *models.Vaccination) error {
+	date, err := time.Parse(time.RFC3339, input.Date)
+	if err != nil {
+		return errors.New("Invalid date format")
+	}
+
+	vaccination.Vaccine = input.Vaccine
+	vaccination.DoseNumber = input.DoseNumber
+	vaccination.Date = date.UTC()
+	vaccination.LotNumber = input.LotNumber
+	vaccination.Clinic = input.Clinic
+	vaccination.Note = input.Note
+	return validateVaccination(*vaccination)
+}
+
+// buildVaccineSchedule matches the recorded vaccinations against the doses of
+// a schedule. Vaccinations match by vaccine name and dose number; those that
+// match no scheduled dose are returned as extra.
+func buildVaccineSchedule(birth time.Time, schedule vaccines.Schedule, vaccinations []models.Vaccination, now time.Time) gin.H {
+	type doseKey struct {
+		vaccine string
+		dose    int
+	}
+	given := make(map[doseKey]models.Vaccination, len(vaccinations))
+	for _, vaccination := range vaccinations {
+		given[doseKey{vaccination.Vaccine, vaccination.DoseNumber}] = vaccination
+	}
+
+	doses := make([]gin.H, len(schedule.Doses))
+	counts := map[string]int{vaccineGiven: 0, vaccineUpcoming: 0, vaccineDue: 0, vaccineOverdue: 0}
+	scheduled := make(map[doseKey]bool, len(schedule.Doses))
+	for i, dose := range schedule.Doses {
+		key := doseKey{dose.Vaccine, dose.Dose}
+		scheduled[key] = true
+
+		dueDate := dose.DueDate(birth)
+		overdueDate := dose.OverdueDate(birth)
+		entry := gin.H{
+			"vaccine":     dose.Vaccine,
+			"dose":        dose.Dose,
+			"ageMonths":   dose.AgeMonths,
+			"dueDate":     dueDate.Format(time.RFC3339),
+			"overdueDate": overdueDate.Format(time.RFC3339),
+		}
+
+		var status string
+		if vaccination, ok := given[key]; ok {
+			status = vaccineGiven
+			entry["vaccination"] = vaccination
+		} else if now.Before(dueDate) {
+			status = vaccineUpcoming
+		} else if now.Before(overdueDate) {
+			status = vaccineDue
+		} else {
+			status = vaccineOverdue
+		}
+		entry["status"] = status
+		counts[status]++
+		doses[i] = entry
+	}
+
+	extra := []models.Vaccination{}
+	for _, vaccination := range vaccinations {
+		if !scheduled[doseKey{vaccination.Vaccine, vaccination.DoseNumber}] {
+			extra = append(extra, vaccination)
+		}
+	}
+
+	return gin.H{
+		"country": schedule.Country,
+		"name":    schedule.Name,
+		"doses":   doses,
+		"counts":  counts,
+		"extra":   extra,
+	}
+}
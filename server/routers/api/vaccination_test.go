@@ -0,0 +1,68 @@
+package api
+
+import (
+	"baby-tracker/models"
+	"baby-tracker/vaccines"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBuildVaccineScheduleStatus(t *testing.T) {
+	schedule, ok := vaccines.Get("us")
+	if !ok {
+		t.Fatal("us schedule not bundled")
+	}
+	birth := time.Date(2024, 1, 10, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		vaccinations []models.Vaccination
+		vaccine      string
+		dose         int
+		want         string
+	}{
+		{"birth dose on the day of birth", birth, nil, "HepB", 1, vaccineDue},
+		{"birth dose within its first month", birth.AddDate(0, 0, 20), nil, "HepB", 1, vaccineDue},
+		{"birth dose after its first month", birth.AddDate(0, 1, 1), nil, "HepB", 1, vaccineOverdue},
+		{"birth dose given", birth.AddDate(0, 2, 0), []models.Vaccination{{Vaccine: "HepB", DoseNumber: 1, Date: birth}}, "HepB", 1, vaccineGiven},
+		{"before the recommended age", birth.AddDate(0, 1, 0), nil, "DTaP", 1, vaccineUpcoming},
+		{"at the recommended age", birth.AddDate(0, 2, 0), nil, "DTaP", 1, vaccineDue},
+		{"when the window closes", birth.AddDate(0, 3, 0), nil, "DTaP", 1, vaccineOverdue},
+		{"within an explicit window", birth.AddDate(0, 14, 0), nil, "MMR", 1, vaccineDue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := buildVaccineSchedule(birth, schedule, tt.vaccinations, tt.now)
+			for _, entry := range result["doses"].([]gin.H) {
+				if entry["vaccine"] == tt.vaccine && entry["dose"] == tt.dose {
+					if entry["status"] != tt.want {
+						t.Errorf("status = %v, want %s", entry["status"], tt.want)
+					}
+					return
+				}
+			}
+			t.Fatalf("%s dose %d not in schedule", tt.vaccine, tt.dose)
+		})
+	}
+}
+
+func TestBuildVaccineScheduleExtra(t *testing.T) {
+	schedule, _ := vaccines.Get("us")
+	birth := time.Date(2024, 1, 10, 8, 0, 0, 0, time.UTC)
+	vaccinations := []models.Vaccination{
+		{Vaccine: "HepB", DoseNumber: 1, Date: birth},
+		{Vaccine: "BCG", DoseNumber: 1, Date: birth},
+	}
+
+	result := buildVaccineSchedule(birth, schedule, vaccinations, birth.AddDate(0, 0, 1))
+	extra := result["extra"].([]models.Vaccination)
+	if len(extra) != 1 || extra[0].Vaccine != "BCG" {
+		t.Errorf("extra = %+v, want only BCG", extra)
+	}
+	if counts := result["counts"].(map[string]int); counts[vaccineGiven] != 1 {
+		t.Errorf("given count = %d, want 1", counts[vaccineGiven])
+	}
+}
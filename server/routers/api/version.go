@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errVersionConflict is returned when a record changed since the version an
+// update was based on.
+var errVersionConflict = errors.New("the record was changed by someone else")
+
+// ifMatchVersion returns the version an update was based on, from the
+// If-Match header carrying the record's ETag. It writes the error response
+// and returns false when the header is missing or isn't a version.
+func ifMatchVersion(c *gin.Context) (int, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the version being edited is required"})
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid If-Match header"})
+		return 0, false
+	}
+	return version, true
+}
+
+// setETag sets the ETag header of a response to a record's version.
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", `"`+strconv.Itoa(version)+`"`)
+}
+
+// preconditionFailed answers an update based on an outdated version with the
+// current record, so that the client can merge its changes into it.
+func preconditionFailed(c *gin.Context, version int, current gin.H) {
+	setETag(c, version)
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": "The record was changed by someone else", "current": current})
+}
+
+// saveVersion saves every field of record, whose Version has been bumped
+// from version, as long as the stored record is still at that version.
+// Otherwise it returns errVersionConflict and nothing is written.
+func saveVersion(tx *gorm.DB, record interface{}, version int) error {
+	result := tx.Model(record).Where("version = ?", version).Select("*").Updates(record)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+// Package storage stores uploaded files. Blobs are addressed by a key chosen
+// by the caller; the store used is set up once at startup by Connect.
+package storage
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+)
+
+// ErrNotFound is returned when no blob exists for a key.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore saves and retrieves opaque blobs by key.
+type BlobStore interface {
+	// Put stores the content of r under key, replacing any existing blob,
+	// and returns the number of bytes written.
+	Put(key string, r io.Reader) (int64, error)
+	// Get opens the blob stored under key. The caller must close it.
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is
+	// not an error.
+	Delete(key string) error
+}
+
+var Store BlobStore
+
+// Connect sets up the local filesystem store in STORAGE_DIR, or in ./uploads
+// when it isn't set.
+func Connect() {
+	dir := os.Getenv("STORAGE_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		log.Fatal("Failed to set up file storage:", err)
+	}
+	Store = store
+}
@@ -0,0 +1,91 @@
+// Package vaccines provides the national immunization schedules bundled in the
+// data directory, one JSON file per country code.
+package vaccines
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Dose is a dose of a vaccine recommended at a given age.
+type Dose struct {
+	Vaccine   string `json:"vaccine"`
+	Dose      int    `json:"dose"`
+	AgeMonths int    `json:"ageMonths"`
+	// LatestAgeMonths is the end of the recommended window; the dose is
+	// overdue after it. Defaults to one month after AgeMonths.
+	LatestAgeMonths int `json:"latestAgeMonths"`
+}
+
+// Schedule is a national immunization schedule.
+type Schedule struct {
+	Country string `json:"country"`
+	Name    string `json:"name"`
+	Doses   []Dose `json:"doses"`
+}
+
+//go:embed data/*.json
+var dataFS embed.FS
+
+var schedules = map[string]Schedule{}
+
+func init() {
+	files, err := dataFS.ReadDir("data")
+	if err != nil {
+		panic(fmt.Sprintf("vaccines: reading data: %v", err))
+	}
+
+	for _, file := range files {
+		raw, err := dataFS.ReadFile(path.Join("data", file.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("vaccines: reading %s: %v", file.Name(), err))
+		}
+
+		var schedule Schedule
+		if err := json.Unmarshal(raw, &schedule); err != nil {
+			panic(fmt.Sprintf("vaccines: parsing %s: %v", file.Name(), err))
+		}
+
+		schedule.Country = strings.TrimSuffix(file.Name(), ".json")
+		for i, dose := range schedule.Doses {
+			if dose.LatestAgeMonths <= dose.AgeMonths {
+				schedule.Doses[i].LatestAgeMonths = dose.AgeMonths + 1
+			}
+		}
+		sort.SliceStable(schedule.Doses, func(i, j int) bool {
+			return schedule.Doses[i].AgeMonths < schedule.Doses[j].AgeMonths
+		})
+		schedules[schedule.Country] = schedule
+	}
+}
+
+// Get returns the schedule of a country code, e.g. "fr" or "us".
+func Get(country string) (Schedule, bool) {
+	schedule, ok := schedules[strings.ToLower(country)]
+	return schedule, ok
+}
+
+// Countries lists the country codes with a bundled schedule.
+func Countries() []string {
+	countries := make([]string, 0, len(schedules))
+	for country := range schedules {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+	return countries
+}
+
+// DueDate returns when a dose is recommended for a child born on birth.
+func (d Dose) DueDate(birth time.Time) time.Time {
+	return birth.AddDate(0, d.AgeMonths, 0)
+}
+
+// OverdueDate returns when the recommended window for a dose closes.
+func (d Dose) OverdueDate(birth time.Time) time.Time {
+	return birth.AddDate(0, d.LatestAgeMonths, 0)
+}
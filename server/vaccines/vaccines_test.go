@@ -0,0 +1,46 @@
+package vaccines
+
+import "testing"
+
+func TestDefaultWindow(t *testing.T) {
+	schedule, ok := Get("us")
+	if !ok {
+		t.Fatal("us schedule not bundled")
+	}
+
+	tests := []struct {
+		vaccine string
+		dose    int
+		latest  int
+	}{
+		{"HepB", 1, 1},  // birth dose without a window
+		{"RV", 1, 3},    // later dose without a window
+		{"HepB", 2, 2},  // explicit window
+		{"HepA", 2, 29}, // explicit window
+	}
+	for _, tt := range tests {
+		found := false
+		for _, dose := range schedule.Doses {
+			if dose.Vaccine == tt.vaccine && dose.Dose == tt.dose {
+				found = true
+				if dose.LatestAgeMonths != tt.latest {
+					t.Errorf("%s dose %d: LatestAgeMonths = %d, want %d", tt.vaccine, tt.dose, dose.LatestAgeMonths, tt.latest)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s dose %d not in schedule", tt.vaccine, tt.dose)
+		}
+	}
+}
+
+func TestEveryDoseHasAWindow(t *testing.T) {
+	for _, country := range Countries() {
+		schedule, _ := Get(country)
+		for _, dose := range schedule.Doses {
+			if dose.LatestAgeMonths <= dose.AgeMonths {
+				t.Errorf("%s: %s dose %d closes at %d months, when it is due", country, dose.Vaccine, dose.Dose, dose.LatestAgeMonths)
+			}
+		}
+	}
+}
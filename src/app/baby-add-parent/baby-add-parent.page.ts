@@ -17,12 +17,11 @@ import { IonSpinner } from "@ionic/angular/standalone";
 export class BabyAddParentPage implements ViewWillEnter {
     babyService = inject(BabyService);
     route = inject(ActivatedRoute);
-    babyId = this.route.snapshot.params["id"];
+    code = this.route.snapshot.params["code"];
     router = inject(Router);
 
     async ionViewWillEnter() {
-        await this.babyService.addBabyById(this.babyId);
-        await this.babyService.refresh();
+        await this.babyService.acceptInvitation(this.code);
         await this.router.navigate(["/settings"]);
     }
 }
@@ -238,6 +238,7 @@ export class CalendarPage implements ViewWillEnter {
                         start: new Date(sleep.start),
                         end: new Date(sleep.end),
                         id: sleep.id,
+                        version: sleep.version,
                     },
                 });
             }
@@ -262,6 +263,7 @@ export class CalendarPage implements ViewWillEnter {
                     text: `Diaper: ${diaper.type}`,
                     diaper: {
                         id: diaper.id,
+                        version: diaper.version,
                         type: diaper.type as DiaperType,
                         time: new Date(diaper.time),
                     },
@@ -280,6 +282,7 @@ export class CalendarPage implements ViewWillEnter {
                     text: `Nursing: ${nursing.type}`,
                     nursing: {
                         id: nursing.id,
+                        version: nursing.version,
                         type: nursing.type as NursingType,
                         time: new Date(nursing.time),
                         amount: nursing.amount as
@@ -367,6 +370,7 @@ export class CalendarPage implements ViewWillEnter {
     async onSleepEdit(event: SleepInput | undefined) {
         if (event && this.selectedActivity?.sleep?.id) {
             event.id = this.selectedActivity.sleep.id;
+            event.version = this.selectedActivity.sleep.version;
 
             await this.storageService.editSleep(event);
             await this.refreshData();
@@ -377,6 +381,7 @@ export class CalendarPage implements ViewWillEnter {
     async onDiaperEdit(event: DiaperInput | undefined) {
         if (event && this.selectedActivity?.diaper?.id) {
             event.id = this.selectedActivity.diaper.id;
+            event.version = this.selectedActivity.diaper.version;
             await this.storageService.editDiaper(event);
             await this.refreshData();
         }
@@ -386,6 +391,7 @@ export class CalendarPage implements ViewWillEnter {
     async onNursingEdit(event: NursingInput | undefined) {
         if (event && this.selectedActivity?.nursing?.id) {
             event.id = this.selectedActivity.nursing.id;
+            event.version = this.selectedActivity.nursing.version;
             await this.storageService.editNursing(event);
             await this.refreshData();
         }
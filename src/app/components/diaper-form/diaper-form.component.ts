@@ -130,6 +130,7 @@ export class DiaperFormComponent {
   diaperOutput = output<DiaperInput | undefined>();
   babiesList = viewChild(BabiesListComponent);
   id = signal<string | undefined>(undefined);
+  version = signal<number | undefined>(undefined);
   waterIcon = waterOutline;
   solidIcon = nutritionOutline;
   bothIcon = syncOutline;
@@ -144,6 +145,7 @@ export class DiaperFormComponent {
     this.time.set(roundedTime);
     this.note.set(undefined);
     this.id.set(undefined);
+    this.version.set(undefined);
     this.babiesList()?.refresh();
     this.form.patchValue({
       time: roundedTime.toISOString().slice(11, 16),
@@ -189,6 +191,7 @@ export class DiaperFormComponent {
       type: diaper.type as DiaperType,
     });
     this.id.set(diaper.id);
+    this.version.set(diaper.version);
     this.note.set(diaper.note);
   }
 
@@ -206,6 +209,7 @@ export class DiaperFormComponent {
         note: this.note(),
         babyId: this.babiesList()?.choosenBaby()?.id,
         id: this.id(),
+        version: this.version(),
       });
     }
   }
@@ -3,6 +3,7 @@ export type DiaperInput = {
     time: Date;
     note?: string;
     id?: string;
+    version?: number;
     babyId?: string;
 };
 
@@ -11,6 +12,7 @@ export type SleepInput = {
     end: Date;
     note?: string;
     id?: string;
+    version?: number;
     babyId?: string;
 };
 
@@ -26,6 +28,7 @@ export type NursingInput = {
     time: Date;
     note?: string;
     id?: string;
+    version?: number;
     babyId?: string;
 };
 
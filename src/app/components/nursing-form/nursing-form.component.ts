@@ -241,6 +241,7 @@ export class NursingFormComponent {
     time = signal<Date>(now());
     nursingOutput = output<NursingInput | undefined>();
     id = signal<string | undefined>(undefined);
+    version = signal<number | undefined>(undefined);
     reset() {
         this.babiesList()?.refresh();
         const defaultTime = new Date();
@@ -254,6 +255,7 @@ export class NursingFormComponent {
         });
         this.time.set(roundedTime);
         this.id.set(undefined);
+        this.version.set(undefined);
         this.form.patchValue({
             time: roundedTime.toISOString().slice(11, 16),
             type: "both",
@@ -301,6 +303,7 @@ export class NursingFormComponent {
                 note: this.form.value.note || undefined,
                 babyId: this.babiesList()?.choosenBaby()?.id,
                 id: this.id(),
+                version: this.version(),
             });
         }
     }
@@ -320,6 +323,7 @@ export class NursingFormComponent {
             note: nursing.note,
         });
         this.id.set(nursing.id);
+        this.version.set(nursing.version);
     }
 
     formatTime(event: any) {
@@ -182,6 +182,7 @@ export class SleepFormComponent {
       note: sleep.note,
     });
     this.id.set(sleep.id);
+    this.version.set(sleep.version);
   }
 
   public saveSleep() {
@@ -206,6 +207,7 @@ export class SleepFormComponent {
         end: endDate,
         babyId: this.babiesList()?.choosenBaby()?.id,
         id: this.id(),
+        version: this.version(),
         note: this.form.value.note ?? undefined,
       });
     }
@@ -217,12 +219,14 @@ export class SleepFormComponent {
   sleepEnd = signal<Date | undefined>(undefined);
   sleepOutput = output<SleepInput | undefined>();
   id = signal<string | undefined>(undefined);
+  version = signal<number | undefined>(undefined);
   constructor() {
   }
 
   async reset() {
     // Clear the id first to ensure we're creating a new entry
     this.id.set(undefined);
+    this.version.set(undefined);
     
     this.babiesList()?.refresh();
     const lastTimer = await this.storageService.getLastTimer();
@@ -8,6 +8,7 @@ import { HeadersService } from "./headers.service";
 
 interface AuthResponse {
     token: string;
+    refreshToken: string;
     user: {
         id: string;
         username: string;
@@ -51,6 +52,11 @@ export class AuthService {
                 this.isAuthenticated.next(true);
                 return true;
             }
+            // The token may just have expired
+            if (await this.headersService.refreshTokens(token)) {
+                this.isAuthenticated.next(true);
+                return true;
+            }
             // Token is invalid, remove it
             await this.headersService.removeToken();
         }
@@ -93,7 +99,10 @@ export class AuthService {
 
         if (response.status === 200) {
             const authResponse = response.data as AuthResponse;
-            await this.headersService.setToken(authResponse.token);
+            await this.headersService.setToken(
+                authResponse.token,
+                authResponse.refreshToken,
+            );
             this.isAuthenticated.next(true);
         } else {
             throw new Error("Login failed");
@@ -114,7 +123,10 @@ export class AuthService {
 
         if (response.status === 200) {
             const authResponse = response.data as AuthResponse;
-            await this.headersService.setToken(authResponse.token);
+            await this.headersService.setToken(
+                authResponse.token,
+                authResponse.refreshToken,
+            );
             this.isAuthenticated.next(true);
         } else {
             throw new Error("Registration failed");
@@ -122,6 +134,14 @@ export class AuthService {
     }
 
     async logout(): Promise<void> {
+        try {
+            // Revokes the refresh token too
+            await this.headersService.post({
+                url: `${this.baseUrl}/auth/logout`,
+            });
+        } catch (error) {
+            console.error("Logout failed:", error);
+        }
         await this.headersService.removeToken();
         this.isAuthenticated.next(false);
         this.router.navigate(["/login"]);
@@ -1,6 +1,5 @@
 import { computed, effect, inject, Injectable, signal } from "@angular/core";
 import { Baby } from "../components/models";
-import { CapacitorHttp } from "@capacitor/core";
 import { environment } from "src/environments/environment";
 import { Device } from "@capacitor/device";
 import { Preferences } from "@capacitor/preferences";
@@ -35,14 +34,9 @@ export class BabyService {
         }
     }
 
-    async headers() {
-        return await this.headersService.getHeaders();
-    }
-
     async checkUserExists() {
-        const response = await CapacitorHttp.get({
+        const response = await this.headersService.get({
             url: `${this.apiUrl}/user`,
-            headers: await this.headers(),
         });
         if (response.status === 404) {
             await this.createUser();
@@ -51,18 +45,16 @@ export class BabyService {
 
     async createUser() {
         const deviceID = await Device.getId();
-        await CapacitorHttp.post({
+        await this.headersService.post({
             url: `${this.apiUrl}/user`,
-            headers: await this.headers(),
             data: { id: deviceID.identifier },
         });
     }
 
     async makeBaby(name: string) {
         try {
-            const response = await CapacitorHttp.post({
+            const response = await this.headersService.post({
                 url: `${this.apiUrl}/baby`,
-                headers: await this.headers(),
                 data: { name },
             });
             const newBaby = response.data;
@@ -79,12 +71,27 @@ export class BabyService {
         }
     }
 
-    async addBabyById(id: string) {
-        const response = await CapacitorHttp.post({
-            url: `${this.apiUrl}/baby/${id}/parent`,
-            headers: await this.headers(),
-            data: { babyId: id },
+    // Invitations replace sharing the baby id: the code is single-use and
+    // expires, so only the person it was sent to can join.
+    async createInvitation(baby: Baby) {
+        const response = await this.headersService.post({
+            url: `${this.apiUrl}/invitation`,
+            data: { babyId: baby.id },
+        });
+        if (response.status !== 200) {
+            throw new Error(response.data?.error ?? "Failed to create invitation");
+        }
+        return response.data;
+    }
+
+    async acceptInvitation(code: string) {
+        const response = await this.headersService.post({
+            url: `${this.apiUrl}/invitation/accept`,
+            data: { code },
         });
+        if (response.status !== 200) {
+            throw new Error(response.data?.error ?? "Failed to accept invitation");
+        }
         console.log("Baby", response.data);
         await this.refresh();
         return response.data;
@@ -100,9 +107,8 @@ export class BabyService {
 
     async refresh() {
         try {
-            const response = await CapacitorHttp.get({
+            const response = await this.headersService.get({
                 url: `${this.apiUrl}/baby`,
-                headers: await this.headers(),
             });
 
             // Ensure response.data is an array
@@ -135,9 +141,8 @@ export class BabyService {
 
     async deleteBaby(baby: Baby) {
         try {
-            await CapacitorHttp.delete({
+            await this.headersService.delete({
                 url: `${this.apiUrl}/baby/${baby.id}`,
-                headers: await this.headers(),
             });
             this.babies.update((babies) =>
                 babies.filter((b) => b.id !== baby.id)
@@ -160,9 +165,8 @@ export class BabyService {
 
     async editBaby(baby: Baby) {
         try {
-            await CapacitorHttp.put({
+            await this.headersService.put({
                 url: `${this.apiUrl}/baby/${baby.id}`,
-                headers: await this.headers(),
                 data: { name: baby.name },
             });
             this.babies.update((babies) =>
@@ -1,12 +1,20 @@
 import { Injectable } from "@angular/core";
+import { CapacitorHttp, HttpOptions, HttpResponse } from "@capacitor/core";
 import { Device } from "@capacitor/device";
 import { Preferences } from "@capacitor/preferences";
+import { environment } from "src/environments/environment";
 
 @Injectable({
     providedIn: "root",
 })
 export class HeadersService {
     private JWT_TOKEN_KEY = "jwt_token";
+    private REFRESH_TOKEN_KEY = "refresh_token";
+    private baseUrl = environment.apiUrl.replace("/api", "");
+
+    // The refresh in flight, shared by every request that got a 401 meanwhile:
+    // the server revokes the session when a refresh token is used twice.
+    private refreshing: Promise<boolean> | null = null;
 
     async getAuthHeaders(): Promise<Record<string, string>> {
         const token = await this.getStoredToken();
@@ -25,11 +33,87 @@ export class HeadersService {
         };
     }
 
-    async setToken(token: string): Promise<void> {
+    // request sends an authenticated request. When the access token has
+    // expired it is refreshed once and the request is retried.
+    async request(options: HttpOptions): Promise<HttpResponse> {
+        const send = async () =>
+            CapacitorHttp.request({
+                ...options,
+                headers: { ...(await this.getHeaders()), ...options.headers },
+            });
+        const token = await this.getStoredToken();
+        const response = await send();
+        if (response.status !== 401 || !(await this.refreshTokens(token))) {
+            return response;
+        }
+        return send();
+    }
+
+    get(options: HttpOptions): Promise<HttpResponse> {
+        return this.request({ ...options, method: "GET" });
+    }
+
+    post(options: HttpOptions): Promise<HttpResponse> {
+        return this.request({ ...options, method: "POST" });
+    }
+
+    put(options: HttpOptions): Promise<HttpResponse> {
+        return this.request({ ...options, method: "PUT" });
+    }
+
+    delete(options: HttpOptions): Promise<HttpResponse> {
+        return this.request({ ...options, method: "DELETE" });
+    }
+
+    // refreshTokens exchanges the refresh token for a new token pair after
+    // failedToken was rejected, and reports whether a valid token is stored.
+    refreshTokens(failedToken: string | null): Promise<boolean> {
+        if (!this.refreshing) {
+            this.refreshing = this.refresh(failedToken).finally(() => {
+                this.refreshing = null;
+            });
+        }
+        return this.refreshing;
+    }
+
+    private async refresh(failedToken: string | null): Promise<boolean> {
+        const token = await this.getStoredToken();
+        if (token && token !== failedToken) {
+            // Another request refreshed it already
+            return true;
+        }
+        const refreshToken = await this.getStoredRefreshToken();
+        if (!refreshToken) {
+            return false;
+        }
+        try {
+            const response = await CapacitorHttp.post({
+                url: `${this.baseUrl}/auth/refresh`,
+                headers: { "Content-Type": "application/json" },
+                data: { refreshToken },
+            });
+            if (response.status !== 200) {
+                return false;
+            }
+            await this.setToken(response.data.token, response.data.refreshToken);
+            return true;
+        } catch (error) {
+            console.error("Token refresh failed:", error);
+            return false;
+        }
+    }
+
+    async setToken(token: string, refreshToken?: string): Promise<void> {
         await Preferences.set({
             key: this.JWT_TOKEN_KEY,
             value: token,
         });
+        if (refreshToken) {
+            await Preferences.set({
+                key: this.REFRESH_TOKEN_KEY,
+                value: refreshToken,
+            });
+        }
     }
 
     async getStoredToken(): Promise<string | null> {
@@ -37,7 +121,15 @@ export class HeadersService {
         return value;
     }
 
+    async getStoredRefreshToken(): Promise<string | null> {
+        const { value } = await Preferences.get({
+            key: this.REFRESH_TOKEN_KEY,
+        });
+        return value;
+    }
+
     async removeToken(): Promise<void> {
         await Preferences.remove({ key: this.JWT_TOKEN_KEY });
+        await Preferences.remove({ key: this.REFRESH_TOKEN_KEY });
     }
 }
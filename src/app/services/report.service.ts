@@ -1,5 +1,4 @@
 import { effect, inject, Injectable, signal } from "@angular/core";
-import { CapacitorHttp } from "@capacitor/core";
 import { environment } from "src/environments/environment";
 import { BabyService } from "./baby.service";
 import { AuthService } from "./auth.service";
@@ -9,17 +8,20 @@ export interface DailyReport {
     date: string;
     diapers: Array<{
         id: string;
+        version: number;
         type: string;
         time: string;
     }>;
     nursings: Array<{
         id: string;
+        version: number;
         time: string;
         type: string;
         amount: string;
     }>;
     sleeps: Array<{
         id: string;
+        version: number;
         start: string;
         end: string;
     }>;
@@ -60,9 +62,8 @@ export class ReportService {
         }
 
         const queryParams = date ? `?date=${date}` : "";
-        const response = await CapacitorHttp.get({
+        const response = await this.headersService.get({
             url: `${this.apiUrl}/report/${activeBaby.id}${queryParams}`,
-            headers: await this.headersService.getHeaders(),
         });
 
         return response.data;
@@ -75,9 +76,8 @@ export class ReportService {
         }
 
         const queryParams = endDate ? `?endDate=${endDate}` : "";
-        const response = await CapacitorHttp.get({
+        const response = await this.headersService.get({
             url: `${this.apiUrl}/report/${activeBaby.id}/weekly${queryParams}`,
-            headers: await this.headersService.getHeaders(),
         });
 
         return response.data;
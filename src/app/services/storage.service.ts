@@ -4,7 +4,6 @@ import { ToastController } from "@ionic/angular/standalone";
 import { DiaperInput, NursingInput, SleepInput } from "../components/models";
 import { formatDisplayTime } from "../components/util";
 import { BabyService } from "./baby.service";
-import { CapacitorHttp } from "@capacitor/core";
 import { environment } from "src/environments/environment";
 import { Device } from "@capacitor/device";
 import { ReportService } from "./report.service";
@@ -112,9 +111,8 @@ export class StorageService {
         const today = new Date().toISOString().split("T")[0];
 
         try {
-            const response = await CapacitorHttp.get({
+            const response = await this.headersService.get({
                 url: `${this.apiUrl}/report/${activeBaby.id}/history/${today}`,
-                headers: await this.headersService.getHeaders(),
             });
 
             if (response.data) {
@@ -145,9 +143,8 @@ export class StorageService {
         const today = new Date().toISOString().split("T")[0];
 
         try {
-            const response = await CapacitorHttp.get({
+            const response = await this.headersService.get({
                 url: `${this.apiUrl}/report/${activeBaby.id}/history/${today}`,
-                headers: await this.headersService.getHeaders(),
             });
 
             if (response.data && Array.isArray(response.data.sleeps)) {
@@ -182,9 +179,8 @@ export class StorageService {
         const dateString = date ??
             new Date().toISOString().split("T")[0].replace(/-/g, "/");
         try {
-            const response = await CapacitorHttp.get({
+            const response = await this.headersService.get({
                 url: `${this.apiUrl}/sleep/${babyId}/date/${dateString}`,
-                headers: await this.headersService.getHeaders(),
             });
             return response.data.totalHoursSlept ?? 0;
         } catch (error) {
@@ -201,9 +197,8 @@ export class StorageService {
         }
 
         try {
-            const response = await CapacitorHttp.post({
+            const response = await this.headersService.post({
                 url: `${this.apiUrl}/sleep`,
-                headers: await this.headersService.getHeaders(),
                 data: sleep,
             });
             const newSleep = response.data;
@@ -222,9 +217,8 @@ export class StorageService {
 
     async deleteSleep(sleepId: string) {
         try {
-            await CapacitorHttp.delete({
+            await this.headersService.delete({
                 url: `${this.apiUrl}/sleep/${sleepId}`,
-                headers: await this.headersService.getHeaders(),
             });
 
             this.sleeps.update((sleeps) =>
@@ -244,9 +238,8 @@ export class StorageService {
         }
 
         try {
-            const response = await CapacitorHttp.post({
+            const response = await this.headersService.post({
                 url: `${this.apiUrl}/diaper`,
-                headers: await this.headersService.getHeaders(),
                 data: diaper,
             });
             const newDiaper = response.data;
@@ -264,52 +257,54 @@ export class StorageService {
     }
 
     async editSleep(sleep: SleepInput) {
-        try {
-            await CapacitorHttp.put({
-                url: `${this.apiUrl}/sleep/${sleep.id}`,
-                headers: await this.headersService.getHeaders(),
-                data: sleep,
-            });
-            await this.refresh();
-        } catch (error) {
-            await this.showToast("Failed to edit sleep record");
-            throw error;
-        }
+        await this.editRecord("sleep", sleep);
     }
 
     async editDiaper(diaper: DiaperInput) {
-        try {
-            await CapacitorHttp.put({
-                url: `${this.apiUrl}/diaper/${diaper.id}`,
-                headers: await this.headersService.getHeaders(),
-                data: diaper,
-            });
-            await this.refresh();
-        } catch (error) {
-            await this.showToast("Failed to edit diaper record");
-            throw error;
-        }
+        await this.editRecord("diaper", diaper);
     }
 
     async editNursing(nursing: NursingInput) {
+        await this.editRecord("nursing", nursing);
+    }
+
+    // editRecord saves an edited record along with the version it was based
+    // on. When someone else changed the record meanwhile the server refuses
+    // the edit, and the current data is shown so that it can be made again.
+    private async editRecord(
+        kind: "sleep" | "diaper" | "nursing",
+        record: SleepInput | DiaperInput | NursingInput,
+    ) {
+        let response;
         try {
-            await CapacitorHttp.put({
-                url: `${this.apiUrl}/nursing/${nursing.id}`,
-                headers: await this.headersService.getHeaders(),
-                data: nursing,
+            response = await this.headersService.put({
+                url: `${this.apiUrl}/${kind}/${record.id}`,
+                headers: { "If-Match": `"${record.version ?? 1}"` },
+                data: record,
             });
-            await this.refresh();
         } catch (error) {
-            await this.showToast("Failed to edit nursing record");
+            await this.showToast(`Failed to edit ${kind} record`);
             throw error;
         }
+
+        if (response.status === 412) {
+            await this.refresh();
+            await this.showToast(
+                `This ${kind} was changed by someone else, please edit it again`,
+            );
+            throw new Error(`The ${kind} was changed by someone else`);
+        }
+        if (response.status >= 400) {
+            await this.showToast(`Failed to edit ${kind} record`);
+            throw new Error(response.data?.error ?? `Failed to edit ${kind}`);
+        }
+        await this.refresh();
     }
 
     async deleteDiaper(diaperId: string) {
         try {
-            await CapacitorHttp.delete({
+            await this.headersService.delete({
                 url: `${this.apiUrl}/diaper/${diaperId}`,
-                headers: await this.headersService.getHeaders(),
             });
 
             this.diapers.update((diapers) =>
@@ -330,9 +325,8 @@ export class StorageService {
         }
 
         try {
-            const response = await CapacitorHttp.post({
+            const response = await this.headersService.post({
                 url: `${this.apiUrl}/nursing`,
-                headers: await this.headersService.getHeaders(),
                 data: nursing,
             });
             const newNursing = response.data;
@@ -353,9 +347,8 @@ export class StorageService {
 
     async deleteNursing(nursingId: string) {
         try {
-            await CapacitorHttp.delete({
+            await this.headersService.delete({
                 url: `${this.apiUrl}/nursing/${nursingId}`,
-                headers: await this.headersService.getHeaders(),
             });
 
             this.nursings.update((nursings) =>
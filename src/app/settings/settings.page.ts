@@ -153,14 +153,19 @@ export class SettingsPage implements ViewWillEnter {
     await alert.present();
   }
 
-  async openAddBabyByIdAlert() {
+  async openAcceptInvitationAlert() {
     const alert = await this.alertController.create({
-      header: "Add baby by id",
-      inputs: [{ name: "id", type: "text", placeholder: "Baby id" }],
+      header: "Join a baby",
+      message: "Enter the invitation code you received",
+      inputs: [{ name: "code", type: "text", placeholder: "Invitation code" }],
       buttons: [
         {
-          text: "Add",
-          handler: (data) => this.babyService.addBabyById(data.id),
+          text: "Join",
+          handler: (data) => {
+            this.babyService.acceptInvitation(data.code).catch((error) =>
+              this.showError(error.message)
+            );
+          },
         },
         { text: "Cancel", role: "cancel" },
       ],
@@ -168,15 +173,32 @@ export class SettingsPage implements ViewWillEnter {
     await alert.present();
   }
 
-  makeMailToBaby(baby: Baby) {
+  async makeMailToBaby(baby: Baby) {
+    let invitation;
+    try {
+      invitation = await this.babyService.createInvitation(baby);
+    } catch (error: any) {
+      await this.showError(error.message);
+      return;
+    }
+    const expiresAt = new Date(invitation.expiresAt).toLocaleDateString();
     EmailComposer.open({
       subject: "Baby Tracker - Add baby to your account",
       body:
-        `To add ${baby.name} to your account, copy the id and add the baby in the settings page. <br> Baby ID: ${baby.id}`,
+        `To add ${baby.name} to your account, open the settings page, tap "join baby with code" and enter this invitation code. <br> Invitation code: ${invitation.code} <br> The code can be used once and expires on ${expiresAt}.`,
       isHtml: true,
     });
   }
 
+  private async showError(message: string) {
+    const alert = await this.alertController.create({
+      header: "Error",
+      message,
+      buttons: ["OK"],
+    });
+    await alert.present();
+  }
+
   async toggleStartOnHistory(event: any) {
     await Preferences.set({
       key: "startOnHistory",